@@ -2,66 +2,107 @@
 package internal
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"reflect"
-	"strconv"
+	"slices"
+	"strings"
 
 	"github.com/isaacwallace123/GoWeb/app/types"
+	"github.com/isaacwallace123/GoWeb/pkg/validator"
 )
 
+// BindingError reports a handler argument that could not be bound from the request.
+type BindingError struct {
+	Param  string // Path variable name, or "body" for the request body
+	Reason string
+	Status int // HTTP status to answer with; 400 when zero
+}
+
+func (e *BindingError) Error() string {
+	return fmt.Sprintf("invalid value for parameter '%s': %s", e.Param, e.Reason)
+}
+
+// BindArguments produces the handler arguments for a request. Each parameter
+// is resolved by the first matching rule: an argument resolver, the path
+// variable argNames pairs it with, then the body and tagged fields for
+// structs, maps and slices. Structs whose fields all carry a binding tag
+// bind from their tags alone and never need a body.
+//
+// Body parameters are checked against their `validate` tags once bound; a
+// failure is reported as validator.Errors rather than a BindingError.
 func BindArguments(
-	req *http.Request,
-	ctx context.Context,
+	mwCtx *types.MiddlewareContext,
 	paramTypes []reflect.Type,
 	pathVars map[string]string,
 	argNames []string,
+	opts types.DecodeOptions,
 ) ([]reflect.Value, error) {
-	args := []reflect.Value{}
-	start := 0
-
-	hasCtx := len(paramTypes) > 0 && paramTypes[0] == reflect.TypeOf((*context.Context)(nil)).Elem()
-	if hasCtx {
-		args = append(args, reflect.ValueOf(ctx))
-		start = 1
-	}
-
+	req := mwCtx.Request
+	ctx := req.Context()
 	ctx = types.WithPathVars(ctx, pathVars)
 	ctx = types.WithQueryParams(ctx, req)
 	ctx = types.WithHeaderMap(ctx, req.Header)
 
-	for i := start; i < len(paramTypes); i++ {
-		t := paramTypes[i]
-		argIdx := i - start
+	req = req.WithContext(ctx)
+	mwCtx.Request = req
 
-		name := ""
-		if argIdx < len(argNames) {
-			name = argNames[argIdx]
-		}
+	args := make([]reflect.Value, 0, len(paramTypes))
+	values := newRequestValues(req)
 
-		if t.Kind() == reflect.Struct && (req.Method == http.MethodPost || req.Method == http.MethodPut) {
-			ptr := reflect.New(t).Interface()
-			err := json.NewDecoder(req.Body).Decode(ptr)
+	for i, t := range paramTypes {
+		if resolver := findResolver(t); resolver != nil {
+			resolved, err := resolver.Resolve(mwCtx, t)
 			if err != nil {
 				return nil, err
 			}
-			args = append(args, reflect.ValueOf(reflect.ValueOf(ptr).Elem().Interface()))
+			args = append(args, resolved)
 			continue
 		}
 
-		if val, ok := pathVars[name]; ok {
-			switch t.Kind() {
-			case reflect.Int:
-				intVal, err := strconv.Atoi(val)
-				if err != nil {
-					return nil, fmt.Errorf("invalid int for %s: %v", name, err)
+		name := ""
+		if i < len(argNames) {
+			name = argNames[i]
+		}
+
+		if val, ok := pathVars[name]; ok && name != "" {
+			// Catch-all segments bind to []string, one element per segment
+			if isStringSlice(t) {
+				segments := []string{}
+				if val != "" {
+					segments = strings.Split(val, "/")
 				}
-				args = append(args, reflect.ValueOf(intVal))
-			default:
-				args = append(args, reflect.ValueOf(val))
+				args = append(args, reflect.ValueOf(segments).Convert(t))
+				continue
+			}
+
+			converted, err := convertValue(val, t)
+			if err != nil {
+				return nil, &BindingError{Param: name, Reason: err.Error()}
+			}
+			args = append(args, converted)
+			continue
+		}
+
+		if isBodyType(t) {
+			bind := bindBody
+			if isTagOnly(t) {
+				bind = bindTags
 			}
+			arg, err := bind(mwCtx.ResponseWriter, req, t, values, opts)
+			if err != nil {
+				return nil, err
+			}
+			if err := validator.Validate(arg.Interface()); err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
 			continue
 		}
 
@@ -70,3 +111,399 @@ func BindArguments(
 
 	return args, nil
 }
+
+// buildArgNames pairs each handler argument with the path variable it binds
+// to. Names declared in the route's Params are checked against the path, and
+// every argument that could take a path variable needs an entry; otherwise path variables are handed out in order to the arguments that can
+// hold one, so resolved arguments and body structs never consume a name.
+func buildArgNames(route *CompiledRoute) ([]string, error) {
+	handlerType := route.Handler.Type()
+	names := make([]string, handlerType.NumIn())
+
+	if route.Params != nil {
+		if len(route.Params) > len(names) {
+			return nil, fmt.Errorf("%d params declared for a handler with %d arguments", len(route.Params), len(names))
+		}
+
+		var errs []error
+		for i, name := range route.Params {
+			if name == "" {
+				continue
+			}
+			t := handlerType.In(i)
+			switch {
+			case !slices.Contains(route.ParamNames, name):
+				errs = append(errs, fmt.Errorf("parameter %d: path has no variable %q", i, name))
+			case !isPathBindable(t):
+				errs = append(errs, fmt.Errorf("parameter %d: cannot bind path variable %q to type %s", i, name, t))
+			}
+			names[i] = name
+		}
+		// A short list would silently leave the remaining arguments unbound
+		for i := len(route.Params); i < len(names); i++ {
+			if t := handlerType.In(i); findResolver(t) == nil && isPathBindable(t) {
+				errs = append(errs, fmt.Errorf("parameter %d: no entry in params; use \"\" if it isn't a path variable", i))
+			}
+		}
+		return names, errors.Join(errs...)
+	}
+
+	next := 0
+	for i := range names {
+		if next == len(route.ParamNames) {
+			break
+		}
+		t := handlerType.In(i)
+		if findResolver(t) != nil || !isPathBindable(t) {
+			continue
+		}
+		names[i] = route.ParamNames[next]
+		next++
+	}
+	return names, nil
+}
+
+// isPathBindable reports whether a path variable can bind to type t.
+func isPathBindable(t reflect.Type) bool {
+	return canConvert(t) || isStringSlice(t)
+}
+
+// isStringSlice reports whether t is a []string, which catch-all path variables bind to.
+func isStringSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String
+}
+
+// checkBodyParams rejects handlers with more than one argument that reads
+// the request body, since only one of them could ever get it. Structs whose
+// fields all bind from tags don't read the body.
+func checkBodyParams(route *CompiledRoute) error {
+	handlerType := route.Handler.Type()
+
+	var readers []string
+	for i := 0; i < handlerType.NumIn(); i++ {
+		t := handlerType.In(i)
+		if findResolver(t) != nil || route.ArgNames[i] != "" || !isBodyType(t) || isTagOnly(t) {
+			continue
+		}
+		readers = append(readers, fmt.Sprintf("%d (%s)", i, t))
+	}
+
+	if len(readers) > 1 {
+		return fmt.Errorf("parameters %s all read the request body; only one can", strings.Join(readers, ", "))
+	}
+	return nil
+}
+
+// isTagOnly reports whether t is a struct, or a pointer to one, whose fields
+// all bind from query, header, cookie or form tags. Such structs have nothing
+// to decode from a JSON body. Fields tagged `json:"-"` can't come from the
+// body either, so they don't count.
+func isTagOnly(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || canConvert(t) {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if !isTagOnly(field.Type) {
+				return false
+			}
+			continue
+		}
+		if _, ok := parseBinding(field); !ok && field.Tag.Get("json") != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+// isBodyType reports whether parameters of type t are decoded from the request body.
+func isBodyType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return !canConvert(t)
+	case reflect.Pointer:
+		return t.Elem().Kind() == reflect.Struct && !canConvert(t.Elem())
+	case reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
+
+// hasBody reports whether the request carries a body, whatever its method.
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0
+}
+
+// bindBody decodes the request body into a new value of type t, then applies
+// tagged fields to structs. Form bodies only feed `form` tagged fields; any
+// other content type is decoded as JSON. With an empty body, pointer
+// parameters are nil and maps and slices are empty, but a struct with JSON
+// fields on POST, PUT or PATCH is rejected: declare it as a pointer if the
+// body is optional. Pointers to structs with tagged fields are still
+// allocated, so those fields bind.
+func bindBody(
+	w http.ResponseWriter,
+	req *http.Request,
+	t reflect.Type,
+	values *requestValues,
+	opts types.DecodeOptions,
+) (reflect.Value, error) {
+	present := hasBody(req)
+
+	target := t
+	if t.Kind() == reflect.Pointer {
+		target = t.Elem()
+	}
+
+	// Tagged fields still bind without a body, so only leave plain pointers nil
+	if t.Kind() == reflect.Pointer && !present && !hasTaggedFields(target) {
+		return reflect.Zero(t), nil
+	}
+	ptr := reflect.New(target)
+
+	if present && isForm(req) {
+		// Without form fields the values would be dropped without a word
+		if target.Kind() != reflect.Struct || !hasFormFields(target) {
+			return reflect.Value{}, &BindingError{
+				Param:  "body",
+				Reason: "form bodies can only bind to structs with form tagged fields",
+				Status: http.StatusUnsupportedMediaType,
+			}
+		}
+		if err := parseForm(w, req, values, opts); err != nil {
+			return reflect.Value{}, err
+		}
+	} else if present {
+		if err := decodeJSON(w, req, ptr.Interface(), opts); err != nil {
+			return reflect.Value{}, err
+		}
+	} else if target.Kind() == reflect.Struct && !isTagOnly(target) && expectsBody(req.Method) {
+		return reflect.Value{}, &BindingError{Param: "body", Reason: "request body is empty"}
+	}
+
+	if target.Kind() == reflect.Struct {
+		if err := bindTaggedFields(values, ptr.Elem()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	if t.Kind() == reflect.Pointer {
+		return ptr, nil
+	}
+	return ptr.Elem(), nil
+}
+
+// decodeJSON decodes the request body into target, enforcing opts.
+func decodeJSON(w http.ResponseWriter, req *http.Request, target any, opts types.DecodeOptions) error {
+	body := req.Body
+	if opts.MaxBodyBytes > 0 {
+		if req.ContentLength > opts.MaxBodyBytes {
+			return tooLargeError(opts.MaxBodyBytes)
+		}
+		body = http.MaxBytesReader(w, body, opts.MaxBodyBytes)
+	}
+
+	dec := json.NewDecoder(body)
+	if opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if opts.UseNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(target); err != nil {
+		return bodyError(err)
+	}
+
+	if opts.DisallowTrailingData {
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return tooLargeError(maxErr.Limit)
+			}
+			return &BindingError{Param: "body", Reason: "unexpected data after the JSON value"}
+		}
+	}
+	return nil
+}
+
+// isForm reports whether the request body is a URL-encoded or multipart form.
+func isForm(req *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// parseForm reads a form body into values, whatever the request method.
+// Multipart parts beyond opts.MaxMemory are written to temporary files, which
+// are removed once the handler returns.
+func parseForm(w http.ResponseWriter, req *http.Request, values *requestValues, opts types.DecodeOptions) error {
+	if values.form != nil {
+		return nil // Already parsed for another argument
+	}
+	if opts.MaxBodyBytes > 0 {
+		if req.ContentLength > opts.MaxBodyBytes {
+			return tooLargeError(opts.MaxBodyBytes)
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, opts.MaxBodyBytes)
+	}
+
+	if reader, err := req.MultipartReader(); err == nil {
+		maxMemory := opts.MaxMemory
+		if maxMemory <= 0 {
+			maxMemory = defaultMaxMemory
+		}
+
+		form, err := reader.ReadForm(maxMemory)
+		if err != nil {
+			return formError(err)
+		}
+		req.MultipartForm = form
+		values.form = form.Value
+		values.files = form.File
+		return nil
+	}
+
+	body := req.Body
+	if opts.MaxBodyBytes <= 0 {
+		// URL-encoded forms are read whole, so cap them even without a limit
+		body = http.MaxBytesReader(w, body, defaultMaxFormBytes)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return formError(err)
+	}
+	form, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return formError(err)
+	}
+	values.form = form
+	return nil
+}
+
+// defaultMaxMemory matches the threshold net/http uses for ParseMultipartForm.
+const defaultMaxMemory = 32 << 20
+
+// defaultMaxFormBytes matches the cap net/http puts on URL-encoded bodies in
+// ParseForm when no MaxBodyBytes is set.
+const defaultMaxFormBytes = 10 << 20
+
+func formError(err error) *BindingError {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return tooLargeError(maxErr.Limit)
+	}
+	if errors.Is(err, multipart.ErrMessageTooLarge) {
+		return &BindingError{Param: "body", Reason: "form is too large", Status: http.StatusRequestEntityTooLarge}
+	}
+	return &BindingError{Param: "body", Reason: "malformed form: " + err.Error()}
+}
+
+func tooLargeError(limit int64) *BindingError {
+	return &BindingError{
+		Param:  "body",
+		Reason: fmt.Sprintf("request body exceeds %d bytes", limit),
+		Status: http.StatusRequestEntityTooLarge,
+	}
+}
+
+// bindTags fills a struct whose fields all bind from tags, never decoding the
+// body as JSON. A form body is parsed only for structs with `form` fields.
+// Pointers are always set.
+func bindTags(
+	w http.ResponseWriter,
+	req *http.Request,
+	t reflect.Type,
+	values *requestValues,
+	opts types.DecodeOptions,
+) (reflect.Value, error) {
+	target := t
+	if t.Kind() == reflect.Pointer {
+		target = t.Elem()
+	}
+	ptr := reflect.New(target)
+
+	if hasFormFields(target) && hasBody(req) && isForm(req) {
+		if err := parseForm(w, req, values, opts); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	if err := bindTaggedFields(values, ptr.Elem()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	if t.Kind() == reflect.Pointer {
+		return ptr, nil
+	}
+	return ptr.Elem(), nil
+}
+
+// hasFormFields reports whether struct type t has any `form` tagged field.
+func hasFormFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && hasFormFields(field.Type) {
+			return true
+		}
+		if binding, ok := parseBinding(field); ok && binding.source == "form" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTaggedFields reports whether struct t has any field bound from a
+// query, header, cookie or form tag.
+func hasTaggedFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && hasTaggedFields(field.Type) {
+			return true
+		}
+		if _, ok := parseBinding(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expectsBody reports whether a method normally carries a request body.
+func expectsBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// bodyError describes a JSON decoding failure, naming the offending field when known.
+func bodyError(err error) *BindingError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &BindingError{
+			Param:  "body." + typeErr.Field,
+			Reason: fmt.Sprintf("expected %s, got JSON %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return tooLargeError(maxErr.Limit)
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &BindingError{Param: "body." + strings.Trim(field, `"`), Reason: "unknown field"}
+	}
+	if errors.Is(err, io.EOF) {
+		return &BindingError{Param: "body", Reason: "request body is empty"}
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return &BindingError{Param: "body", Reason: "malformed JSON: unexpected end of input"}
+	}
+	return &BindingError{Param: "body", Reason: "malformed JSON: " + err.Error()}
+}
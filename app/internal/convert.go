@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// canConvert reports whether convertValue can produce a value of type t.
+func canConvert(t reflect.Type) bool {
+	if t == timeType || t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// convertValue parses a raw request string into a value of type t. It covers
+// every scalar kind (including named types such as `type UserID int64`),
+// time.Time in RFC 3339 or YYYY-MM-DD form, time.Duration, and any type whose
+// pointer implements encoding.TextUnmarshaler.
+func convertValue(raw string, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	switch t {
+	case timeType:
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if parsed, err = time.Parse(time.DateOnly, raw); err != nil {
+				return reflect.Value{}, invalidValue(raw, "timestamp")
+			}
+		}
+		return reflect.ValueOf(parsed), nil
+
+	case durationType:
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return reflect.Value{}, invalidValue(raw, "duration")
+		}
+		return reflect.ValueOf(parsed), nil
+	}
+
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(raw)); err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not a valid %s: %v", raw, t, err)
+		}
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return reflect.Value{}, invalidValue(raw, t.String())
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, invalidValue(raw, t.String())
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, invalidValue(raw, t.String())
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, t.Bits())
+		if err != nil {
+			return reflect.Value{}, invalidValue(raw, t.String())
+		}
+		v.SetFloat(f)
+
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(raw, t.Bits())
+		if err != nil {
+			return reflect.Value{}, invalidValue(raw, t.String())
+		}
+		v.SetComplex(c)
+
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot bind to %s", t)
+	}
+
+	return v, nil
+}
+
+func invalidValue(raw, kind string) error {
+	return fmt.Errorf("%q is not a valid %s", raw, kind)
+}
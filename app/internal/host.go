@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// hostTree holds the routes of controllers bound to a host pattern such as
+// "admin.example.com" or "{tenant}.api.example.com".
+type hostTree struct {
+	key     string         // Matcher source, shared by patterns that differ only in names
+	matcher *regexp.Regexp // Case-insensitive, anchored
+	groups  []int          // Submatch index of each parameter in matcher
+	static  bool           // True when the pattern has no parameters
+	root    *node
+}
+
+// compileHost turns a host pattern into its key, matcher and parameter names.
+// Parameters match a single label unless they carry a constraint.
+func compileHost(pattern string) (string, *regexp.Regexp, []string, error) {
+	params, err := parseParams(pattern)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var source strings.Builder
+	names := make([]string, 0, len(params))
+	last := 0
+
+	source.WriteString("(?i)^")
+	for i, p := range params {
+		expr := p.constraint
+		if expr == "" {
+			expr = "[^.]+"
+		}
+		source.WriteString(regexp.QuoteMeta(pattern[last:p.start]))
+		fmt.Fprintf(&source, "(?P<p%d>%s)", i, expr)
+		names = append(names, p.name)
+		last = p.end
+	}
+	source.WriteString(regexp.QuoteMeta(pattern[last:]))
+	source.WriteString("$")
+
+	matcher, err := regexp.Compile(source.String())
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid constraint in host %q: %w", pattern, err)
+	}
+	return source.String(), matcher, names, nil
+}
+
+// hostRoot returns the path tree for a host pattern, creating it if needed,
+// and records the parameter names the pattern declares.
+func (t *RouteTree) hostRoot(pattern string, names *[]string) (*node, error) {
+	key, matcher, hostNames, err := compileHost(pattern)
+	if err != nil {
+		return nil, err
+	}
+	*names = append(*names, hostNames...)
+
+	for _, h := range t.hosts {
+		if h.key == key {
+			return h.root, nil
+		}
+	}
+
+	created := &hostTree{
+		key:     key,
+		matcher: matcher,
+		groups:  captureGroups(matcher, len(hostNames)),
+		static:  len(hostNames) == 0,
+		root:    &node{},
+	}
+
+	// Exact hosts are tried before patterns with parameters.
+	if created.static {
+		i := 0
+		for i < len(t.hosts) && t.hosts[i].static {
+			i++
+		}
+		t.hosts = append(t.hosts[:i], append([]*hostTree{created}, t.hosts[i:]...)...)
+	} else {
+		t.hosts = append(t.hosts, created)
+	}
+	return created.root, nil
+}
+
+// candidate is a path tree that applies to a request, with the values
+// captured from its host pattern.
+type candidate struct {
+	root   *node
+	values []string
+}
+
+// candidates lists the path trees that apply to a request host, most specific
+// first. Routes without a host pattern always come last.
+func (t *RouteTree) candidates(host string) []candidate {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	var out []candidate
+	for _, h := range t.hosts {
+		m := h.matcher.FindStringSubmatch(host)
+		if m == nil {
+			continue
+		}
+		values := make([]string, len(h.groups))
+		for i, group := range h.groups {
+			values[i] = m[group]
+		}
+		out = append(out, candidate{root: h.root, values: values})
+	}
+	return append(out, candidate{root: t.root})
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// paramConstraints are the built-in constraints usable as {name:constraint}.
+// Anything else after the colon is treated as a regular expression.
+var paramConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// isWildcard reports whether a segment is a catch-all: "*" or "{name...}".
+func isWildcard(seg string) bool {
+	return seg == "*" || (strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}"))
+}
+
+// wildcardName returns the parameter name a catch-all segment binds to.
+// The anonymous "*" binds to "*".
+func wildcardName(seg string) string {
+	if seg == "*" {
+		return seg
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "...}"))
+}
+
+// pathParam is a single {name} or {name:constraint} placeholder in a segment.
+type pathParam struct {
+	start, end int    // Byte offsets of the placeholder, braces included
+	name       string // Parameter name
+	constraint string // Regex source, empty when unconstrained
+}
+
+// parseParams finds the placeholders in a path segment. Braces are balanced,
+// so constraints may contain quantifiers such as {3}.
+func parseParams(seg string) ([]pathParam, error) {
+	var params []pathParam
+
+	for i := 0; i < len(seg); i++ {
+		if seg[i] != '{' {
+			continue
+		}
+
+		end, depth := -1, 0
+		for j := i; j < len(seg) && end < 0; j++ {
+			switch seg[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{' in path segment %q", seg)
+		}
+
+		name, constraint, _ := strings.Cut(seg[i+1:end], ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("empty parameter name in path segment %q", seg)
+		}
+		if builtin, ok := paramConstraints[constraint]; ok {
+			constraint = builtin
+		}
+
+		params = append(params, pathParam{start: i, end: end + 1, name: name, constraint: constraint})
+		i = end
+	}
+
+	return params, nil
+}
+
+// compileSegment turns a parameter segment into its node key, matcher and
+// parameter names. Names are not part of the key, so "{id}" and "{userId}"
+// at the same position share a node. A plain "{name}" segment has no matcher.
+func compileSegment(seg string) (string, *regexp.Regexp, []string, error) {
+	params, err := parseParams(seg)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if len(params) == 1 && params[0].start == 0 && params[0].end == len(seg) && params[0].constraint == "" {
+		return "{}", nil, []string{params[0].name}, nil
+	}
+
+	var pattern strings.Builder
+	names := make([]string, 0, len(params))
+	last := 0
+
+	pattern.WriteString("^")
+	for i, p := range params {
+		expr := p.constraint
+		if expr == "" {
+			expr = "[^/]+"
+		}
+		pattern.WriteString(regexp.QuoteMeta(seg[last:p.start]))
+		fmt.Fprintf(&pattern, "(?P<p%d>%s)", i, expr)
+		names = append(names, p.name)
+		last = p.end
+	}
+	pattern.WriteString(regexp.QuoteMeta(seg[last:]))
+	pattern.WriteString("$")
+
+	matcher, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid constraint in path segment %q: %w", seg, err)
+	}
+	return pattern.String(), matcher, names, nil
+}
+
+// captureGroups returns the submatch index of each parameter in a compiled matcher.
+func captureGroups(matcher *regexp.Regexp, count int) []int {
+	groups := make([]int, count)
+	for i := range groups {
+		groups[i] = matcher.SubexpIndex(fmt.Sprintf("p%d", i))
+	}
+	return groups
+}
+
+// expandPath fills the parameters of a route's host and path patterns with
+// escaped values. Catch-all values keep their slashes; every other value is
+// escaped as a single segment and checked against its constraint; catch-all
+// values can't contain "." or ".." segments. Values not used by the patterns are returned
+// as leftovers. Routes bound to a host expand to a scheme-relative URL.
+func expandPath(host, pattern string, values url.Values) (string, url.Values, error) {
+	leftovers := make(url.Values, len(values))
+	for k, v := range values {
+		leftovers[k] = v
+	}
+
+	take := func(name string) (string, error) {
+		if !leftovers.Has(name) {
+			return "", fmt.Errorf("missing value for path parameter %q", name)
+		}
+		value := leftovers.Get(name)
+		leftovers.Del(name)
+		return value, nil
+	}
+
+	prefix := ""
+	if host != "" {
+		expanded, err := expandSegment(host, "[^.]+", take)
+		if err != nil {
+			return "", nil, err
+		}
+		prefix = "//" + expanded
+	}
+
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		if isWildcard(seg) {
+			value, err := take(wildcardName(seg))
+			if err != nil {
+				return "", nil, err
+			}
+			parts := strings.Split(value, "/")
+			for j := range parts {
+				if parts[j] == "." || parts[j] == ".." {
+					return "", nil, fmt.Errorf("value %q for path parameter %q contains a %q segment", value, wildcardName(seg), parts[j])
+				}
+				parts[j] = url.PathEscape(parts[j])
+			}
+			segments[i] = strings.Join(parts, "/")
+			continue
+		}
+
+		expanded, err := expandSegment(seg, "[^/]+", take)
+		if err != nil {
+			return "", nil, err
+		}
+		segments[i] = expanded
+	}
+
+	return prefix + "/" + strings.Join(segments, "/"), leftovers, nil
+}
+
+// expandSegment replaces each placeholder in seg with its escaped value.
+// Values must satisfy the placeholder's constraint, or anyValue without one,
+// so the URL can be routed.
+func expandSegment(seg, anyValue string, take func(name string) (string, error)) (string, error) {
+	params, err := parseParams(seg)
+	if err != nil {
+		return "", err
+	}
+
+	var expanded strings.Builder
+	last := 0
+	for _, p := range params {
+		value, err := take(p.name)
+		if err != nil {
+			return "", err
+		}
+		expr := p.constraint
+		if expr == "" {
+			expr = anyValue
+		}
+		matcher, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return "", fmt.Errorf("invalid constraint for path parameter %q: %w", p.name, err)
+		}
+		if !matcher.MatchString(value) {
+			return "", fmt.Errorf("value %q for path parameter %q doesn't match %s", value, p.name, expr)
+		}
+		expanded.WriteString(seg[last:p.start])
+		expanded.WriteString(url.PathEscape(value))
+		last = p.end
+	}
+	expanded.WriteString(seg[last:])
+	return expanded.String(), nil
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/isaacwallace123/GoUtils/logger"
+	"github.com/isaacwallace123/GoWeb/app/types"
+	"github.com/isaacwallace123/GoWeb/pkg/exception"
+)
+
+// recoverPanic is deferred around a middleware chain. It logs a panic with
+// the request it happened on, hands it to reporter, and answers with a 500
+// unless a response has already been started. http.ErrAbortHandler is let
+// through, since it asks net/http to abort the response on purpose.
+func recoverPanic(ctx *types.MiddlewareContext, reporter types.PanicReporter) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+
+	stack := debug.Stack()
+	logger.Error("panic serving %s %s: %v\n%s", ctx.Request.Method, ctx.Request.URL.Path, recovered, stack)
+
+	if reporter != nil {
+		reporter(ctx, recovered, stack)
+	}
+
+	if written, ok := ctx.ResponseWriter.(interface{ Written() bool }); ok && written.Written() {
+		return
+	}
+	send(exception.InternalServerException("Internal server error"), ctx.ResponseWriter, ctx.Request)
+}
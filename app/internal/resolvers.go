@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/isaacwallace123/GoWeb/app/types"
+)
+
+// builtinResolvers expose the request plumbing to any handler that asks for it.
+var builtinResolvers = []types.ArgumentResolver{
+	types.ResolverFor(func(ctx *types.MiddlewareContext) (context.Context, error) {
+		return ctx.Request.Context(), nil
+	}),
+	types.ResolverFor(func(ctx *types.MiddlewareContext) (*http.Request, error) {
+		return ctx.Request, nil
+	}),
+	types.ResolverFor(func(ctx *types.MiddlewareContext) (http.ResponseWriter, error) {
+		return ctx.ResponseWriter, nil
+	}),
+	types.ResolverFor(func(ctx *types.MiddlewareContext) (*types.MiddlewareContext, error) {
+		return ctx, nil
+	}),
+}
+
+// findResolver returns the first resolver supporting t, checking registered
+// resolvers before the built-in ones.
+func findResolver(t reflect.Type) types.ArgumentResolver {
+	for _, resolver := range types.ArgumentResolvers {
+		if resolver.Supports(t) {
+			return resolver
+		}
+	}
+	for _, resolver := range builtinResolvers {
+		if resolver.Supports(t) {
+			return resolver
+		}
+	}
+	return nil
+}
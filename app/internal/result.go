@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/isaacwallace123/GoUtils/logger"
+	"github.com/isaacwallace123/GoWeb/app/types"
+	"github.com/isaacwallace123/GoWeb/pkg/HttpStatus"
+	"github.com/isaacwallace123/GoWeb/pkg/ResponseEntity"
+	"github.com/isaacwallace123/GoWeb/pkg/exception"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// checkResults reports whether handlerType returns one of the supported
+// shapes: nothing, a single value, or a (value, error) pair.
+func checkResults(handlerType reflect.Type) error {
+	if handlerType.NumOut() < 2 || (handlerType.NumOut() == 2 && handlerType.Out(1) == errorType) {
+		return nil
+	}
+	return fmt.Errorf("handler must return at most a value and an error, got %s", resultList(handlerType))
+}
+
+// handleResults turns what a handler returned into the response to send.
+// Errors are answered by onError. A *types.ResponseEntity is sent as is,
+// and any other value becomes the body of a response with the route's
+// status, 200 by default. Handlers that return nothing, or nil, get 204.
+func handleResults(results []reflect.Value, status int, onError func(error) *types.ResponseEntity) *types.ResponseEntity {
+	if len(results) > 0 {
+		last := results[len(results)-1]
+		if last.Type() == errorType {
+			// A typed nil, like a nil *HTTPError returned as error, is no error
+			if !last.IsNil() && !isNil(last.Elem()) {
+				return onError(last.Interface().(error))
+			}
+			results = results[:len(results)-1]
+		}
+	}
+
+	if len(results) == 0 || isNil(results[0]) {
+		if status == 0 {
+			status = HttpStatus.NO_CONTENT
+		}
+		return ResponseEntity.Status(status)
+	}
+
+	value := results[0].Interface()
+	if resp, ok := value.(*types.ResponseEntity); ok {
+		return resp
+	}
+
+	if status == 0 {
+		status = HttpStatus.OK
+	}
+	return ResponseEntity.Status(status).Body(value)
+}
+
+// isNil reports whether v holds a nil pointer, map, slice or interface.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// resolveError builds the response for err with the first error handler that
+// takes it. Without one, fallback decides.
+func resolveError(
+	ctx *types.MiddlewareContext,
+	handlers []types.ErrorHandler,
+	err error,
+	fallback func(error) *types.ResponseEntity,
+) *types.ResponseEntity {
+	for _, handler := range handlers {
+		if resp := handler.Handle(ctx, err); resp != nil {
+			return resp
+		}
+	}
+	return fallback(err)
+}
+
+// defaultErrorResponse maps an error no handler took through
+// exception.FromError. Errors that aren't an exception.HTTPError are logged,
+// since the client only sees a generic 500.
+func defaultErrorResponse(req *http.Request) func(error) *types.ResponseEntity {
+	return func(err error) *types.ResponseEntity {
+		var httpErr *exception.HTTPError
+		if !errors.As(err, &httpErr) {
+			logger.Error("%s %s: %v", req.Method, req.URL.Path, err)
+		}
+		return exception.FromError(err)
+	}
+}
@@ -1,155 +1,368 @@
 package internal
 
 import (
-	"context"
+	"errors"
+	"fmt"
+	"github.com/isaacwallace123/GoUtils/logger"
+	"github.com/isaacwallace123/GoWeb/pkg/HttpStatus"
+	"github.com/isaacwallace123/GoWeb/pkg/ResponseEntity"
 	"github.com/isaacwallace123/GoWeb/pkg/exception"
+	"github.com/isaacwallace123/GoWeb/pkg/validator"
 	"net/http"
+	"net/url"
 	"reflect"
-	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/isaacwallace123/GoWeb/app/types"
 )
 
-// CompiledRoute struct remains unchanged
+// CompiledRoute is a controller route resolved to its handler method.
 type CompiledRoute struct {
-	Method     string
-	Regex      *regexp.Regexp
-	ParamNames []string
-	Handler    reflect.Value
-	CtrlValue  reflect.Value
+	Name        string
+	Method      string
+	Host        string
+	Path        string
+	ParamNames  []string
+	ArgNames    []string // Path variable bound to each handler argument, "" for none
+	Params      []string // Names declared with types.Route.Params, if any
+	HandlerName string
+	Handler     reflect.Value
+	CtrlValue   reflect.Value
+	Decode      *types.DecodeOptions // Nil to use the router's options
+	Status      int                  // Status for plain return values, 0 for the default
 }
 
-func RegisterControllersImpl(controllers ...types.Controller) []CompiledRoute {
-	var compiled []CompiledRoute
+// Pattern returns the host and path pattern the route was registered under.
+func (route *CompiledRoute) Pattern() string {
+	return route.Host + route.Path
+}
+
+// Describe names the controller type and handler method behind the route.
+func (route *CompiledRoute) Describe() string {
+	return route.CtrlValue.Type().String() + "." + route.HandlerName
+}
+
+// RegisterControllersImpl compiles the routes of the given controllers on top
+// of the routes already in tree. Every route is validated, and all problems
+// are reported together. The result is a new tree, so tree itself is never
+// left half-populated.
+func RegisterControllersImpl(tree *RouteTree, controllers ...types.Controller) (*RouteTree, error) {
+	next := NewRouteTree()
+	var errs []error
+
+	for _, route := range tree.Routes() {
+		if err := next.Insert(route); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
 	for _, ctrl := range controllers {
 		val := reflect.ValueOf(ctrl)
-		typ := reflect.TypeOf(ctrl)
+
+		host := ""
+		if hosted, ok := ctrl.(interface{ Host() string }); ok {
+			host = hosted.Host()
+		}
 
 		for _, entry := range ctrl.Routes() {
-			fullPath := joinPath(ctrl.BasePath(), entry.Path)
-			re, paramNames := compilePathPattern(fullPath)
+			route := &CompiledRoute{
+				Name:        entry.Name,
+				Method:      strings.ToUpper(entry.Method),
+				Host:        host,
+				Path:        joinPath(ctrl.BasePath(), entry.Path),
+				HandlerName: entry.Handler,
+				Handler:     val.MethodByName(entry.Handler),
+				CtrlValue:   val,
+				Decode:      entry.Decode,
+				Params:      entry.Params,
+				Status:      entry.Status,
+			}
+
+			if err := validateRoute(route); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s (%s): %w", route.Method, route.Pattern(), route.Describe(), err))
+				continue
+			}
 
-			if _, ok := typ.MethodByName(entry.Handler); !ok {
-				panic("Handler method not found: " + entry.Handler)
+			if err := next.Insert(route); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 
-			compiled = append(compiled, CompiledRoute{
-				Method:     strings.ToUpper(entry.Method),
-				Regex:      re,
-				ParamNames: paramNames,
-				Handler:    val.MethodByName(entry.Handler),
-				CtrlValue:  val,
-			})
+			// Path variable names are only known once the pattern is compiled
+			argNames, err := buildArgNames(route)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s (%s): %w", route.Method, route.Pattern(), route.Describe(), err))
+				continue
+			}
+			route.ArgNames = argNames
+
+			if err := checkBodyParams(route); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s (%s): %w", route.Method, route.Pattern(), route.Describe(), err))
+			}
 		}
 	}
 
-	return compiled
+	if len(errs) > 0 {
+		return tree, errors.Join(errs...)
+	}
+	return next, nil
+}
+
+// BuildURL expands a route's path with values and appends the values it
+// doesn't use as a query string.
+func BuildURL(route *CompiledRoute, values url.Values) (string, error) {
+	path, query, err := expandPath(route.Host, route.Path, values)
+	if err != nil {
+		return "", fmt.Errorf("route %q: %w", route.Name, err)
+	}
+
+	if len(query) == 0 {
+		return path, nil
+	}
+	return path + "?" + query.Encode(), nil
 }
 
 func ListenImpl(router http.Handler, addr string) error {
 	return http.ListenAndServe(addr, router)
 }
 
-func Dispatch(routes []CompiledRoute, w http.ResponseWriter, req *http.Request) {
-	normalizedPath := normalizePath(req.URL.Path)
+// Settings holds the router-wide options the dispatcher applies to every route.
+type Settings struct {
+	Decode        types.DecodeOptions  // Used by routes without their own Route.Decode
+	ErrorHandlers []types.ErrorHandler // Tried after the controller's own error handlers
+	PanicReporter types.PanicReporter  // Optional, told about recovered panics
+}
 
-	for _, route := range routes {
-		matches := route.Regex.FindStringSubmatch(normalizedPath)
-		if matches == nil {
-			continue
-		}
+// Dispatch serves req with the matching route in tree.
+func Dispatch(tree *RouteTree, settings *Settings, w http.ResponseWriter, req *http.Request) {
+	rw := &responseWriter{ResponseWriter: w}
 
-		if req.Method != route.Method && req.Method != http.MethodOptions {
-			continue
-		}
+	if route, values := tree.Lookup(req.Method, req.Host, req.URL.Path); route != nil {
+		serveRoute(route, values, settings, rw, req)
+		return
+	}
 
-		pathVars := extractPathVars(route.ParamNames, matches[1:])
-		paramTypes := getParamTypes(route.Handler.Type())
-		argNames := buildArgNames(paramTypes, route.ParamNames)
-		args, err := BindArguments(req, req.Context(), paramTypes, pathVars, argNames)
-		if err != nil {
+	// HEAD falls back to GET with the body discarded
+	if req.Method == http.MethodHead {
+		if route, values := tree.Lookup(http.MethodGet, req.Host, req.URL.Path); route != nil {
+			rw.discardBody = true
+			serveRoute(route, values, settings, rw, req)
 			return
 		}
+	}
 
-		// --- Controller-level middleware
-		var ctrlPre []types.Middleware
-		if ctrl, ok := route.CtrlValue.Interface().(interface{ PreMiddleware() []types.Middleware }); ok {
-			ctrlPre = ctrl.PreMiddleware()
+	allowed := tree.Allowed(req.Host, req.URL.Path)
+	if len(allowed) == 0 {
+		if req.Method == http.MethodOptions {
+			runChain(rw, req, types.ConvertMiddewaresToFuncs(types.PreMiddlewares), settings, settings.ErrorHandlers)
+			if rw.Written() {
+				return
+			}
 		}
 
-		var ctrlPost []types.Middleware
-		if ctrl, ok := route.CtrlValue.Interface().(interface{ PostMiddleware() []types.Middleware }); ok {
-			ctrlPost = ctrl.PostMiddleware()
-		}
+		send(exception.NotFoundException("Route not found"), rw, req)
+		return
+	}
 
-		// --- Build the chain
-		chain := make([]types.MiddlewareFunc, 0,
-			len(types.PreMiddlewares)+len(ctrlPre)+1+len(ctrlPost)+len(types.PostMiddlewares),
-		)
-
-		chain = append(chain, types.ConvertMiddewaresToFuncs(types.PreMiddlewares)...)
-		chain = append(chain, types.ConvertMiddewaresToFuncs(ctrlPre)...)
-
-		if req.Method != http.MethodOptions {
-			chain = append(chain, func(ctx *types.MiddlewareContext) error {
-				result := route.Handler.Call(args)
-				if len(result) != 1 {
-					exception.InternalServerException("Expected 1 return value").Send(w)
-					return nil
-				}
-				if resp, ok := result[0].Interface().(*types.ResponseEntity); ok {
-					ctx.ResponseEntity = resp
-				}
+	if i := slices.Index(allowed, http.MethodGet); i >= 0 && !slices.Contains(allowed, http.MethodHead) {
+		allowed = slices.Insert(allowed, i+1, http.MethodHead)
+	}
+	if !slices.Contains(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	allow := strings.Join(allowed, ", ")
+
+	if req.Method != http.MethodOptions {
+		send(exception.MethodNotAllowedException("Method "+req.Method+" not allowed").Header("Allow", allow), rw, req)
+		return
+	}
+
+	// Bare OPTIONS: let middleware such as CORS answer first, otherwise list the methods
+	rw.Header().Set("Allow", allow)
+	route, values := tree.Lookup("", req.Host, req.URL.Path)
+	serveRoute(route, values, settings, rw, req)
+
+	if !rw.Written() {
+		ResponseEntity.Status(HttpStatus.NO_CONTENT).Send(rw)
+	}
+}
+
+// serveRoute runs the middleware chain around a matched route. When the route
+// was borrowed to answer a bare OPTIONS request, the handler itself is skipped.
+func serveRoute(route *CompiledRoute, values []string, settings *Settings, w *responseWriter, req *http.Request) {
+	pathVars := extractPathVars(route.ParamNames, values)
+	paramTypes := getParamTypes(route.Handler.Type())
+
+	decode := settings.Decode
+	if route.Decode != nil {
+		decode = *route.Decode
+	}
+
+	// --- Controller-level middleware
+	var ctrlPre []types.Middleware
+	if ctrl, ok := route.CtrlValue.Interface().(interface{ PreMiddleware() []types.Middleware }); ok {
+		ctrlPre = ctrl.PreMiddleware()
+	}
+
+	var ctrlPost []types.Middleware
+	if ctrl, ok := route.CtrlValue.Interface().(interface{ PostMiddleware() []types.Middleware }); ok {
+		ctrlPost = ctrl.PostMiddleware()
+	}
+
+	// Controller error handlers take precedence over the router's
+	var errorHandlers []types.ErrorHandler
+	if ctrl, ok := route.CtrlValue.Interface().(interface{ ErrorHandlers() []types.ErrorHandler }); ok {
+		errorHandlers = append(errorHandlers, ctrl.ErrorHandlers()...)
+	}
+	errorHandlers = append(errorHandlers, settings.ErrorHandlers...)
+
+	// --- Build the chain
+	chain := make([]types.MiddlewareFunc, 0,
+		len(types.PreMiddlewares)+len(ctrlPre)+1+len(ctrlPost)+len(types.PostMiddlewares),
+	)
+
+	chain = append(chain, types.ConvertMiddewaresToFuncs(types.PreMiddlewares)...)
+	chain = append(chain, types.ConvertMiddewaresToFuncs(ctrlPre)...)
+
+	if req.Method != http.MethodOptions || route.Method == http.MethodOptions {
+		chain = append(chain, func(ctx *types.MiddlewareContext) error {
+			args, err := BindArguments(ctx, paramTypes, pathVars, route.ArgNames, decode)
+			if form := ctx.Request.MultipartForm; form != nil {
+				defer form.RemoveAll()
+			}
+			if err != nil {
+				ctx.ResponseEntity = resolveError(ctx, errorHandlers, err, bindingErrorResponse(ctx.Request))
 				return ctx.Next()
-			})
-		}
+			}
+
+			results := route.Handler.Call(args)
+
+			// Handlers that wrote to the http.ResponseWriter themselves have already answered
+			if !w.Written() {
+				ctx.ResponseEntity = handleResults(results, route.Status, func(err error) *types.ResponseEntity {
+					return resolveError(ctx, errorHandlers, err, defaultErrorResponse(ctx.Request))
+				})
+			}
+			return ctx.Next()
+		})
+	}
 
-		chain = append(chain, types.ConvertMiddewaresToFuncs(ctrlPost)...)
-		chain = append(chain, types.ConvertMiddewaresToFuncs(types.PostMiddlewares)...)
+	chain = append(chain, types.ConvertMiddewaresToFuncs(ctrlPost)...)
+	chain = append(chain, types.ConvertMiddewaresToFuncs(types.PostMiddlewares)...)
 
-		mwCtx := &types.MiddlewareContext{
-			Request:        req,
-			ResponseWriter: w,
-			ResponseEntity: nil,
-			Index:          -1,
-			Chain:          chain,
+	runChain(w, req, chain, settings, errorHandlers)
+}
+
+// bindingErrorResponse turns a failed BindArguments call into a 400 (or 413)
+// response naming the offending parameter, or a 422 listing every field that failed
+// validation. A resolver returning the wrong type is a server bug, so it's
+// logged and answered with a 500. Any other error comes from a resolver and is
+// answered like a handler's error.
+func bindingErrorResponse(req *http.Request) func(error) *types.ResponseEntity {
+	return func(err error) *types.ResponseEntity {
+		var fieldErrs validator.Errors
+		if errors.As(err, &fieldErrs) {
+			return exception.GenericHTTPError(HttpStatus.UNPROCESSABLE_ENTITY, "request validation failed", fieldErrs)
 		}
 
-		_ = mwCtx.Next()
+		var typeErr *types.ResolverTypeError
+		if errors.As(err, &typeErr) {
+			logger.Error("%v", err)
+			return exception.InternalServerException("Internal server error")
+		}
 
-		if mwCtx.ResponseEntity != nil {
-			mwCtx.ResponseEntity.Send(w)
+		var bindErr *BindingError
+		if !errors.As(err, &bindErr) {
+			return defaultErrorResponse(req)(err)
 		}
-		return
+
+		status := bindErr.Status
+		if status == 0 {
+			status = HttpStatus.BAD_REQUEST
+		}
+		return exception.GenericHTTPError(status, bindErr.Error(), map[string]string{
+			"parameter": bindErr.Param,
+			"reason":    bindErr.Reason,
+		})
 	}
+}
 
-	if req.Method == http.MethodOptions {
-		mwCtx := &types.MiddlewareContext{
-			Request:        req,
-			ResponseWriter: w,
-			ResponseEntity: nil,
-			Index:          -1,
-			Chain:          types.ConvertMiddewaresToFuncs(types.PreMiddlewares),
+// runChain executes a middleware chain and sends the resulting ResponseEntity, if any.
+// An error returned by the chain replaces the response, unless one was already written.
+// A panic anywhere in the chain is recovered and answered with a 500.
+func runChain(
+	w http.ResponseWriter,
+	req *http.Request,
+	chain []types.MiddlewareFunc,
+	settings *Settings,
+	errorHandlers []types.ErrorHandler,
+) {
+	mwCtx := &types.MiddlewareContext{
+		Request:        req,
+		ResponseWriter: w,
+		ResponseEntity: nil,
+		Index:          -1,
+		Chain:          chain,
+	}
+	defer recoverPanic(mwCtx, settings.PanicReporter)
+
+	if err := mwCtx.Next(); err != nil {
+		if written, ok := w.(interface{ Written() bool }); ok && written.Written() {
+			return
 		}
-		_ = mwCtx.Next()
-		return
+		mwCtx.ResponseEntity = resolveError(mwCtx, errorHandlers, err, defaultErrorResponse(mwCtx.Request))
 	}
 
-	// Fallback
-	exception.NotFoundException("Route not found").Send(w)
+	if mwCtx.ResponseEntity != nil {
+		send(mwCtx.ResponseEntity, w, req)
+	}
+}
+
+// send writes a response, pointing the instance of a Problem Details body
+// at the request path when the problem doesn't name one.
+func send(resp *types.ResponseEntity, w http.ResponseWriter, req *http.Request) {
+	if problem, ok := resp.BodyData.(*exception.Problem); ok && problem.Instance == "" {
+		// Fill in a copy: the problem may be shared between requests
+		withInstance := *problem
+		withInstance.Instance = req.URL.Path
+		copied := *resp
+		copied.BodyData = &withInstance
+		resp = &copied
+	}
+	resp.Send(w)
 }
 
-// --- Helper functions (unchanged) ---
+// ServeHandler runs a plain http.Handler inside the global middleware chain.
+func ServeHandler(handler http.Handler, settings *Settings, w http.ResponseWriter, req *http.Request) {
+	chain := types.ConvertMiddewaresToFuncs(types.PreMiddlewares)
+	chain = append(chain, func(ctx *types.MiddlewareContext) error {
+		handler.ServeHTTP(ctx.ResponseWriter, ctx.Request)
+		return ctx.Next()
+	})
+	chain = append(chain, types.ConvertMiddewaresToFuncs(types.PostMiddlewares)...)
+
+	// Track writes so errors and panics after the handler answered don't write twice
+	runChain(&responseWriter{ResponseWriter: w}, req, chain, settings, settings.ErrorHandlers)
+}
 
-func normalizePath(path string) string {
-	if path != "/" {
-		return strings.TrimRight(path, "/")
+// StripPrefix returns a shallow copy of req with prefix removed from its
+// path. The resulting path always starts with a slash.
+func StripPrefix(prefix string, req *http.Request) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *req.URL
+
+	r2.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(req.URL.Path, prefix), "/")
+	if req.URL.RawPath != "" {
+		r2.URL.RawPath = "/" + strings.TrimLeft(strings.TrimPrefix(req.URL.RawPath, prefix), "/")
 	}
-	return path
+	return r2
 }
 
+// --- Helper functions ---
+
 func joinPath(base, suffix string) string {
 	base = strings.TrimRight(base, "/")
 	suffix = strings.TrimLeft(suffix, "/")
@@ -160,17 +373,6 @@ func joinPath(base, suffix string) string {
 	return full
 }
 
-func compilePathPattern(path string) (*regexp.Regexp, []string) {
-	paramRegex := regexp.MustCompile(`\{([^}]+)\}`)
-	paramNames := []string{}
-	regexStr := paramRegex.ReplaceAllStringFunc(path, func(m string) string {
-		name := m[1 : len(m)-1]
-		paramNames = append(paramNames, name)
-		return "([^/]+)"
-	})
-	return regexp.MustCompile("^" + regexStr + "/?$"), paramNames
-}
-
 func extractPathVars(names, values []string) map[string]string {
 	vars := make(map[string]string, len(names))
 	for i := range names {
@@ -186,11 +388,3 @@ func getParamTypes(handlerType reflect.Type) []reflect.Type {
 	}
 	return params
 }
-
-func buildArgNames(paramTypes []reflect.Type, routeParams []string) []string {
-	hasContext := len(paramTypes) > 0 && paramTypes[0] == reflect.TypeOf((*context.Context)(nil)).Elem()
-	if hasContext {
-		return append([]string{""}, routeParams...)
-	}
-	return routeParams
-}
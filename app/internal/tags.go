@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// bindingTags are the struct tags that bind a field from the request, in
+// the order they are checked.
+var bindingTags = []string{"query", "header", "cookie", "form"}
+
+var (
+	cookieType      = reflect.TypeOf((*http.Cookie)(nil))
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// requestValues holds the parts of a request that struct tags bind from.
+type requestValues struct {
+	query   map[string][]string
+	header  http.Header
+	cookies []*http.Cookie
+	form    url.Values                         // Set once a form body is parsed
+	files   map[string][]*multipart.FileHeader // Set once a multipart body is parsed
+}
+
+func newRequestValues(req *http.Request) *requestValues {
+	return &requestValues{
+		query:   req.URL.Query(),
+		header:  req.Header,
+		cookies: req.Cookies(),
+	}
+}
+
+// fieldBinding describes where a tagged struct field gets its value from.
+type fieldBinding struct {
+	source   string // "query", "header", "cookie" or "form"
+	name     string // Parameter, header, cookie or form field name
+	required bool   // Set by the ",required" tag option
+}
+
+// param names the binding in error messages, e.g. "header.X-Tenant-Id".
+func (b fieldBinding) param() string {
+	return b.source + "." + b.name
+}
+
+// parseBinding reads the binding tag of a struct field, if it has one.
+// Tags take the form `header:"X-Tenant-Id"` or `header:"X-Tenant-Id,required"`.
+func parseBinding(field reflect.StructField) (fieldBinding, bool) {
+	for _, source := range bindingTags {
+		tag, ok := field.Tag.Lookup(source)
+		if !ok {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		return fieldBinding{
+			source:   source,
+			name:     strings.TrimSpace(name),
+			required: slices.Contains(strings.Split(opts, ","), "required"),
+		}, true
+	}
+	return fieldBinding{}, false
+}
+
+// lookup returns the raw values for a binding.
+func (rv *requestValues) lookup(b fieldBinding) []string {
+	switch b.source {
+	case "query":
+		return rv.query[b.name]
+	case "header":
+		return rv.header.Values(b.name)
+	case "cookie":
+		var values []string
+		for _, c := range rv.cookies {
+			if c.Name == b.name {
+				values = append(values, c.Value)
+			}
+		}
+		return values
+	case "form":
+		return rv.form[b.name]
+	}
+	return nil
+}
+
+// cookie returns the first cookie with the given name, or nil.
+func (rv *requestValues) cookie(name string) *http.Cookie {
+	for _, c := range rv.cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// bindTaggedFields fills the tagged fields of a struct from the request.
+// Fields of embedded structs are bound as if they were declared inline.
+func bindTaggedFields(rv *requestValues, v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindTaggedFields(rv, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		binding, ok := parseBinding(field)
+		if !ok {
+			continue
+		}
+
+		// A *http.Cookie field receives the whole cookie rather than its value
+		if binding.source == "cookie" && field.Type == cookieType {
+			c := rv.cookie(binding.name)
+			if c == nil && binding.required {
+				return &BindingError{Param: binding.param(), Reason: "is required"}
+			}
+			v.Field(i).Set(reflect.ValueOf(c))
+			continue
+		}
+
+		// Uploaded files bind to *multipart.FileHeader or []*multipart.FileHeader
+		if binding.source == "form" && (field.Type == fileHeaderType || field.Type == fileHeadersType) {
+			files := rv.files[binding.name]
+			if len(files) == 0 {
+				if binding.required {
+					return &BindingError{Param: binding.param(), Reason: "is required"}
+				}
+				continue
+			}
+			if field.Type == fileHeaderType {
+				v.Field(i).Set(reflect.ValueOf(files[0]))
+			} else {
+				v.Field(i).Set(reflect.ValueOf(files))
+			}
+			continue
+		}
+
+		values := rv.lookup(binding)
+		if len(values) == 0 && binding.required {
+			return &BindingError{Param: binding.param(), Reason: "is required"}
+		}
+
+		if err := setField(v.Field(i), values, field.Tag.Get("default"), binding.param()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField converts values into a struct field. Absent values fall back to
+// the field's default, unless the field was already set (e.g. by the body).
+// Pointer fields stay nil when there is nothing to bind, and slice fields
+// take every value, so ?tag=a&tag=b binds to []string{"a", "b"}.
+func setField(fv reflect.Value, values []string, def, param string) error {
+	if len(values) == 0 {
+		if def == "" || !fv.IsZero() {
+			return nil
+		}
+		values = strings.Split(def, ",")
+	}
+
+	switch fv.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(fv.Type().Elem())
+		if err := setField(elem.Elem(), values, "", param); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+
+	case reflect.Slice:
+		list := reflect.MakeSlice(fv.Type(), 0, len(values))
+		for _, raw := range values {
+			converted, err := convertValue(raw, fv.Type().Elem())
+			if err != nil {
+				return &BindingError{Param: param, Reason: err.Error()}
+			}
+			list = reflect.Append(list, converted)
+		}
+		fv.Set(list)
+		return nil
+	}
+
+	converted, err := convertValue(values[0], fv.Type())
+	if err != nil {
+		return &BindingError{Param: param, Reason: err.Error()}
+	}
+	fv.Set(converted)
+	return nil
+}
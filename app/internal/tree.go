@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// nodeKind orders the children of a node by match priority.
+type nodeKind uint8
+
+const (
+	staticNode   nodeKind = iota // Literal segment (e.g. "users")
+	paramNode                    // Parameter segment (e.g. "{id}" or "v{version}")
+	wildcardNode                 // Trailing catch-all (e.g. "*" or "{path...}")
+)
+
+// node is a single path segment in the route tree.
+type node struct {
+	kind     nodeKind
+	key      string         // Literal for static nodes, pattern source for param nodes
+	matcher  *regexp.Regexp // Nil for plain "{name}" segments that match anything
+	groups   []int          // Submatch index of each parameter in matcher
+	static   map[string]*node
+	params   []*node
+	wildcard *node
+	routes   map[string]*CompiledRoute // Handlers keyed by HTTP method
+	methods  []string                  // Methods in registration order
+}
+
+// RouteTree is a prefix tree of compiled routes keyed by path segment.
+// Lookups walk the request path one segment at a time, so matching cost
+// grows with the length of the path rather than the number of routes.
+//
+// Routes of controllers bound to a host pattern live in their own trees,
+// which are tried before the routes that accept any host.
+type RouteTree struct {
+	root   *node
+	hosts  []*hostTree
+	routes []*CompiledRoute
+	names  map[string]*CompiledRoute
+}
+
+// NewRouteTree creates an empty route tree.
+func NewRouteTree() *RouteTree {
+	return &RouteTree{root: &node{}}
+}
+
+// Insert adds a compiled route under its path pattern. Two routes that end on
+// the same node with the same method can never both be reached, so the second
+// one is rejected, even if their parameter names differ.
+func (t *RouteTree) Insert(route *CompiledRoute) error {
+	if existing, exists := t.names[route.Name]; exists && route.Name != "" {
+		return fmt.Errorf("route name %q on %s %s (%s) is already used by %s %s (%s)",
+			route.Name, route.Method, route.Pattern(), route.Describe(),
+			existing.Method, existing.Pattern(), existing.Describe(),
+		)
+	}
+
+	n := t.root
+	route.ParamNames = nil
+
+	if route.Host != "" {
+		root, err := t.hostRoot(route.Host, &route.ParamNames)
+		if err != nil {
+			return err
+		}
+		n = root
+	}
+
+	segments := splitPath(route.Path)
+	for i, seg := range segments {
+		if isWildcard(seg) && i != len(segments)-1 {
+			return fmt.Errorf("wildcard %q must be the last path segment", seg)
+		}
+
+		next, err := n.child(seg, &route.ParamNames)
+		if err != nil {
+			return err
+		}
+		n = next
+	}
+
+	// Host and path variables share one namespace, so a repeated name would
+	// silently keep only its last value
+	for i, name := range route.ParamNames {
+		if slices.Contains(route.ParamNames[:i], name) {
+			return fmt.Errorf("parameter %q is declared twice in %s", name, route.Pattern())
+		}
+	}
+
+	if existing, exists := n.routes[route.Method]; exists {
+		return fmt.Errorf("route conflict: %s %s (%s) is shadowed by %s %s (%s)",
+			route.Method, route.Pattern(), route.Describe(),
+			existing.Method, existing.Pattern(), existing.Describe(),
+		)
+	}
+	if n.routes == nil {
+		n.routes = make(map[string]*CompiledRoute)
+	}
+	n.routes[route.Method] = route
+	n.methods = append(n.methods, route.Method)
+	t.routes = append(t.routes, route)
+
+	if route.Name != "" {
+		if t.names == nil {
+			t.names = make(map[string]*CompiledRoute)
+		}
+		t.names[route.Name] = route
+	}
+	return nil
+}
+
+// Named returns the route registered under name, or nil if there is none.
+func (t *RouteTree) Named(name string) *CompiledRoute {
+	return t.names[name]
+}
+
+// Routes returns every route in the tree, in registration order.
+func (t *RouteTree) Routes() []*CompiledRoute {
+	return t.routes
+}
+
+// child returns the child node for a pattern segment, creating it if needed,
+// and records any parameter names the segment declares.
+func (n *node) child(seg string, names *[]string) (*node, error) {
+	if isWildcard(seg) {
+		*names = append(*names, wildcardName(seg))
+		if n.wildcard == nil {
+			n.wildcard = &node{kind: wildcardNode, key: seg}
+		}
+		return n.wildcard, nil
+	}
+
+	if !strings.Contains(seg, "{") {
+		if n.static == nil {
+			n.static = make(map[string]*node)
+		}
+		if existing, ok := n.static[seg]; ok {
+			return existing, nil
+		}
+		created := &node{kind: staticNode, key: seg}
+		n.static[seg] = created
+		return created, nil
+	}
+
+	key, matcher, paramNames, err := compileSegment(seg)
+	if err != nil {
+		return nil, err
+	}
+	*names = append(*names, paramNames...)
+
+	for _, existing := range n.params {
+		if existing.key == key {
+			return existing, nil
+		}
+	}
+
+	created := &node{kind: paramNode, key: key, matcher: matcher}
+	if matcher != nil {
+		created.groups = captureGroups(matcher, len(paramNames))
+	}
+
+	// Plain "{name}" segments match anything, so they go last among params.
+	if matcher != nil {
+		i := 0
+		for i < len(n.params) && n.params[i].matcher != nil {
+			i++
+		}
+		n.params = append(n.params[:i], append([]*node{created}, n.params[i:]...)...)
+	} else {
+		n.params = append(n.params, created)
+	}
+	return created, nil
+}
+
+// capture reports whether a request segment matches this param node and
+// returns the captured values.
+func (n *node) capture(seg string) ([]string, bool) {
+	if n.matcher == nil {
+		return []string{seg}, seg != ""
+	}
+	m := n.matcher.FindStringSubmatch(seg)
+	if m == nil {
+		return nil, false
+	}
+	captured := make([]string, len(n.groups))
+	for i, group := range n.groups {
+		captured[i] = m[group]
+	}
+	return captured, true
+}
+
+// Lookup finds the route registered for method at host and path, along with
+// the captured parameter values in the order the patterns declare them
+// (host first). Static segments beat parameters, and parameters beat
+// wildcards. An empty method matches the first route registered on any method.
+func (t *RouteTree) Lookup(method, host, path string) (*CompiledRoute, []string) {
+	segments := splitPath(path)
+
+	for _, c := range t.candidates(host) {
+		n, values := c.root.match(method, segments, c.values)
+		if n == nil {
+			continue
+		}
+		if method == "" {
+			return n.routes[n.methods[0]], values
+		}
+		return n.routes[method], values
+	}
+	return nil, nil
+}
+
+// handles reports whether the node has a route for method.
+func (n *node) handles(method string) bool {
+	if method == "" {
+		return len(n.methods) > 0
+	}
+	_, ok := n.routes[method]
+	return ok
+}
+
+// match walks the tree depth-first in priority order and returns the first
+// terminal node that handles method.
+func (n *node) match(method string, segments, values []string) (*node, []string) {
+	if len(segments) == 0 {
+		if n.handles(method) {
+			return n, values
+		}
+		if n.wildcard != nil && n.wildcard.handles(method) {
+			return n.wildcard, append(values, "")
+		}
+		return nil, nil
+	}
+
+	seg := segments[0]
+
+	if child, ok := n.static[seg]; ok {
+		if found, vals := child.match(method, segments[1:], values); found != nil {
+			return found, vals
+		}
+	}
+
+	for _, child := range n.params {
+		captured, ok := child.capture(seg)
+		if !ok {
+			continue
+		}
+		if found, vals := child.match(method, segments[1:], append(values, captured...)); found != nil {
+			return found, vals
+		}
+	}
+
+	if n.wildcard != nil && n.wildcard.handles(method) {
+		return n.wildcard, append(values, strings.Join(segments, "/"))
+	}
+
+	return nil, nil
+}
+
+// Allowed lists the methods registered on every route that matches host and
+// path, in registration order.
+func (t *RouteTree) Allowed(host, path string) []string {
+	var methods []string
+	segments := splitPath(path)
+
+	for _, c := range t.candidates(host) {
+		c.root.collect(segments, func(n *node) {
+			for _, method := range n.methods {
+				if !slices.Contains(methods, method) {
+					methods = append(methods, method)
+				}
+			}
+		})
+	}
+	return methods
+}
+
+// collect visits every terminal node that matches the segments, regardless of method.
+func (n *node) collect(segments []string, visit func(*node)) {
+	if n.wildcard != nil {
+		visit(n.wildcard)
+	}
+	if len(segments) == 0 {
+		visit(n)
+		return
+	}
+
+	if child, ok := n.static[segments[0]]; ok {
+		child.collect(segments[1:], visit)
+	}
+	for _, child := range n.params {
+		if _, ok := child.capture(segments[0]); ok {
+			child.collect(segments[1:], visit)
+		}
+	}
+}
+
+// splitPath breaks a path into its segments, ignoring leading and trailing slashes.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
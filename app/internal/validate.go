@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/isaacwallace123/GoWeb/pkg/validator"
+)
+
+// validateRoute checks that a route's handler exists and has a signature the
+// dispatcher can call: bindable parameters and one of the result shapes
+// handleResults understands. Argument resolvers must be registered before the controllers that
+// rely on them.
+func validateRoute(route *CompiledRoute) error {
+	if !route.Handler.IsValid() {
+		return errors.New("handler method not found")
+	}
+
+	handlerType := route.Handler.Type()
+
+	var errs []error
+	if err := checkResults(handlerType); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i := 0; i < handlerType.NumIn(); i++ {
+		t := handlerType.In(i)
+		if findResolver(t) != nil {
+			continue
+		}
+		if !isBindable(t) {
+			errs = append(errs, fmt.Errorf("parameter %d: cannot bind type %s", i, t))
+			continue
+		}
+		if isBodyType(t) {
+			if err := validator.CheckTags(t); err != nil {
+				errs = append(errs, fmt.Errorf("parameter %d: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isBindable reports whether BindArguments knows how to produce a value of
+// type t without an argument resolver.
+func isBindable(t reflect.Type) bool {
+	return canConvert(t) || isStringSlice(t) || isBodyType(t)
+}
+
+// resultList formats a function's result types for error messages.
+func resultList(fnType reflect.Type) string {
+	if fnType.NumOut() == 0 {
+		return "nothing"
+	}
+	out := "("
+	for i := 0; i < fnType.NumOut(); i++ {
+		if i > 0 {
+			out += ", "
+		}
+		out += fnType.Out(i).String()
+	}
+	return out + ")"
+}
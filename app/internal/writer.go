@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// responseWriter records whether a response has been started, so the
+// dispatcher can tell when middleware has already answered a request.
+// With discardBody set, headers go through but the body is dropped,
+// which is how HEAD requests are served by GET handlers.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	discardBody bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discardBody {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data to the client, committing a 200 status if none
+// was written, so streaming handlers such as SSE keep working.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection over to the caller, for WebSocket upgrades and
+// the like. It fails with http.ErrNotSupported when the underlying writer
+// can't be hijacked.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		// The connection is no longer ours to answer on
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Written reports whether a status line has been sent.
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
@@ -20,3 +20,8 @@ func Pre() []types.MiddlewareFunc {
 func Post() []types.MiddlewareFunc {
 	return types.ConvertMiddewaresToFuncs(types.PostMiddlewares)
 }
+
+// Register argument resolvers for custom handler parameter types
+func UseResolver(resolvers ...types.ArgumentResolver) {
+	types.ArgumentResolvers = append(types.ArgumentResolvers, resolvers...)
+}
@@ -1,32 +1,114 @@
 package app
 
 import (
+	"fmt"
 	"github.com/isaacwallace123/GoUtils/logger"
 	"github.com/isaacwallace123/GoWeb/app/internal"
 	"github.com/isaacwallace123/GoWeb/app/types"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
 type Router struct {
-	routes    []internal.CompiledRoute
+	routes    *internal.RouteTree
 	resources []func(http.ResponseWriter, *http.Request) bool
+	settings  internal.Settings
 }
 
 // NewRouter creates a new Router.
 func NewRouter() *Router {
-	return &Router{}
+	return &Router{routes: internal.NewRouteTree()}
 }
 
-// Make RegisterControllers a method on *Router
+// RegisterControllers adds the routes of the given controllers to the router.
+// It can be called any number of times. It panics if a route is invalid; use
+// RegisterControllersE to handle the error instead.
 func (r *Router) RegisterControllers(controllers ...types.Controller) {
-	r.routes = internal.RegisterControllersImpl(controllers...)
+	if err := r.RegisterControllersE(controllers...); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterControllersE adds the routes of the given controllers to the router
+// and returns every problem found: missing handlers, unsupported signatures and
+// conflicting paths. On error, none of the controllers are registered.
+func (r *Router) RegisterControllersE(controllers ...types.Controller) error {
+	routes, err := internal.RegisterControllersImpl(r.routes, controllers...)
+	if err != nil {
+		return err
+	}
+	r.routes = routes
+	return nil
+}
+
+// SetDecodeOptions sets how JSON request bodies are decoded on every route
+// that doesn't set its own Route.Decode.
+//
+//	router.SetDecodeOptions(types.DecodeOptions{
+//		MaxBodyBytes:          1 << 20,
+//		DisallowUnknownFields: true,
+//		DisallowTrailingData:  true,
+//	})
+func (r *Router) SetDecodeOptions(opts types.DecodeOptions) {
+	r.settings.Decode = opts
+}
+
+// UseErrorHandler adds error handlers that turn errors returned by handlers
+// and middleware into responses, like Spring's @ControllerAdvice. They are
+// tried in order, after the controller's own error handlers. Errors no
+// handler takes fall back to exception.FromError.
+//
+//	router.UseErrorHandler(
+//		types.OnError(ErrUserNotFound, func(ctx *types.MiddlewareContext, err error) *types.ResponseEntity {
+//			return exception.NotFoundException("user not found")
+//		}),
+//	)
+func (r *Router) UseErrorHandler(handlers ...types.ErrorHandler) {
+	r.settings.ErrorHandlers = append(r.settings.ErrorHandlers, handlers...)
+}
+
+// OnPanic sets a callback told about every panic recovered while serving a
+// request, after it has been logged. The client always gets a 500.
+func (r *Router) OnPanic(reporter types.PanicReporter) {
+	r.settings.PanicReporter = reporter
+}
+
+// URLFor builds the URL of the route registered under name. Params are
+// name/value pairs: values for path parameters are escaped into the path,
+// and any others are appended as query parameters. A []string value adds
+// the query parameter once per element.
+//
+//	router.URLFor("user", "userid", 42, "expand", "orders") // "/api/v1/users/42?expand=orders"
+func (r *Router) URLFor(name string, params ...any) (string, error) {
+	route := r.routes.Named(name)
+	if route == nil {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("route %q: params must be name/value pairs", name)
+	}
+
+	values := url.Values{}
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("route %q: param name %v is not a string", name, params[i])
+		}
+		if list, ok := params[i+1].([]string); ok {
+			values[key] = append(values[key], list...)
+			continue
+		}
+		values.Add(key, fmt.Sprint(params[i+1]))
+	}
+
+	return internal.BuildURL(route, values)
 }
 
 // Listen starts the HTTP server.
 func (r *Router) Listen(addr string) error { return internal.ListenImpl(r, addr) }
 
-// ServeHTTP first tries static handlers, then dispatches dynamic routes.
+// ServeHTTP first tries static and mounted handlers, then dispatches dynamic routes.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	for _, handler := range r.resources {
 		if handler(w, req) {
@@ -34,7 +116,7 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	internal.Dispatch(r.routes, w, req)
+	internal.Dispatch(r.routes, &r.settings, w, req)
 }
 
 // UseStatic registers a static file handler for the given URL prefix and directory.
@@ -67,3 +149,33 @@ func (r *Router) UseStatic(prefix, dir string) {
 	r.resources = append(r.resources, handler)
 	logger.Info("[Static] Registered: %-12s → %s", prefix, dir)
 }
+
+// Mount serves every request under prefix with handler, after stripping the
+// prefix from the path. Plain handlers run inside the global pre/post
+// middleware; a mounted *Router applies that middleware itself.
+func (r *Router) Mount(prefix string, handler http.Handler) {
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	_, nested := handler.(*Router)
+
+	mounted := func(w http.ResponseWriter, req *http.Request) bool {
+		path := req.URL.Path
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			return false
+		}
+
+		stripped := internal.StripPrefix(prefix, req)
+		if nested {
+			handler.ServeHTTP(w, stripped)
+		} else {
+			internal.ServeHandler(handler, &r.settings, w, stripped)
+		}
+		return true
+	}
+
+	r.resources = append(r.resources, mounted)
+	logger.Info("[Mount] Registered: %-12s → %T", prefix+"/", handler)
+}
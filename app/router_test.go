@@ -1,15 +1,26 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/isaacwallace123/GoWeb/pkg/HttpStatus"
 	"github.com/isaacwallace123/GoWeb/pkg/ResponseEntity"
 	"io"
+	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/isaacwallace123/GoUtils/jsonutil"
 	"github.com/isaacwallace123/GoWeb/app/types"
+	"github.com/isaacwallace123/GoWeb/pkg/exception"
+	"github.com/isaacwallace123/GoWeb/pkg/middlewares"
 )
 
 type TestResponse struct {
@@ -44,6 +55,7 @@ func (c *DummyController) Delete(id string) *ResponseEntity.ResponseEntity {
 func clearAllGlobalState() {
 	types.PreMiddlewares = nil
 	types.PostMiddlewares = nil
+	types.ArgumentResolvers = nil
 }
 
 // For test isolation
@@ -153,3 +165,1883 @@ func TestRouter_DELETE(t *testing.T) {
 		t.Fatalf("DELETE: expected empty body for 204, got %q", bodyStr)
 	}
 }
+
+type PriorityController struct{}
+
+func (c *PriorityController) BasePath() string { return "/files" }
+func (c *PriorityController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/*", Handler: "Wildcard"},
+		{Method: "GET", Path: "/{name}", Handler: "Param"},
+		{Method: "GET", Path: "/latest", Handler: "Static"},
+		{Method: "GET", Path: "/v{version}/readme", Handler: "Versioned"},
+		{Method: "HEAD", Path: "/latest", Handler: "HeadLatest"},
+	}
+}
+func (c *PriorityController) Wildcard() *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "wildcard"})
+}
+func (c *PriorityController) Param(name string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "param", ID: name})
+}
+func (c *PriorityController) Static() *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "static"})
+}
+func (c *PriorityController) Versioned(version string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "versioned", ID: version})
+}
+
+func (c *PriorityController) HeadLatest() *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Header("X-Explicit-Head", "true")
+}
+
+func decodeTestResponse(t *testing.T, w *httptest.ResponseRecorder) TestResponse {
+	t.Helper()
+	var tr TestResponse
+	if err := jsonutil.FromString(strings.TrimSpace(w.Body.String()), &tr); err != nil {
+		t.Fatalf("failed to decode JSON: %v\nRaw body: %s", err, w.Body.String())
+	}
+	return tr
+}
+
+// Static segments beat params, params beat wildcards
+func TestRouter_MatchPriority(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&PriorityController{})
+
+	cases := []struct {
+		path, method, id string
+	}{
+		{"/files/latest", "static", ""},
+		{"/files/report.pdf", "param", "report.pdf"},
+		{"/files/v2/readme", "versioned", "2"},
+		{"/files/a/b/c", "wildcard", ""},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+		if w.Code != HttpStatus.OK {
+			t.Fatalf("%s: want status %d, got %d", tc.path, HttpStatus.OK, w.Code)
+		}
+		tr := decodeTestResponse(t, w)
+		if tr.Method != tc.method || tr.ID != tc.id {
+			t.Errorf("%s: want %s(%q), got %s(%q)", tc.path, tc.method, tc.id, tr.Method, tr.ID)
+		}
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/other", nil))
+
+	if w.Code != HttpStatus.NOT_FOUND {
+		t.Fatalf("want status %d, got %d", HttpStatus.NOT_FOUND, w.Code)
+	}
+}
+
+// PATCH /api/v1/test/{id} is not registered, but PUT and DELETE are
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PATCH", "/api/v1/test/abc123", nil))
+
+	if w.Code != HttpStatus.METHOD_NOT_ALLOWED {
+		t.Fatalf("want status %d, got %d", HttpStatus.METHOD_NOT_ALLOWED, w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "PUT, DELETE, OPTIONS" {
+		t.Errorf("want Allow %q, got %q", "PUT, DELETE, OPTIONS", got)
+	}
+}
+
+// OPTIONS without CORS middleware lists the registered methods
+func TestRouter_BareOPTIONS(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/api/v1/test/", nil))
+
+	if w.Code != HttpStatus.NO_CONTENT {
+		t.Fatalf("want status %d, got %d", HttpStatus.NO_CONTENT, w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, HEAD, POST, OPTIONS" {
+		t.Errorf("want Allow %q, got %q", "GET, HEAD, POST, OPTIONS", got)
+	}
+}
+
+// HEAD runs the GET handler but sends no body
+func TestRouter_HEADFallsBackToGET(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/api/v1/test/", nil))
+
+	if w.Code != HttpStatus.OK {
+		t.Fatalf("want status %d, got %d", HttpStatus.OK, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("want empty body, got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != "16" {
+		t.Errorf("want Content-Length %q, got %q", "16", got)
+	}
+}
+
+// An explicitly registered HEAD route wins over the GET fallback
+func TestRouter_ExplicitHEAD(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&PriorityController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/files/latest", nil))
+
+	if got := w.Header().Get("X-Explicit-Head"); got != "true" {
+		t.Errorf("want explicit HEAD handler to run, got header %q", got)
+	}
+}
+
+type ConstraintController struct{}
+
+func (c *ConstraintController) BasePath() string { return "/items" }
+func (c *ConstraintController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/{name}", Handler: "ByName"},
+		{Method: "GET", Path: "/{id:int}", Handler: "ByID", Name: "item"},
+		{Method: "GET", Path: "/{code:[A-Z]{3}}", Handler: "ByCode"},
+		{Method: "GET", Path: "/uuid/{uuid:uuid}", Handler: "ByUUID"},
+	}
+}
+func (c *ConstraintController) ByName(name string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "name", ID: name})
+}
+func (c *ConstraintController) ByID(id int) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "id", ID: strconv.Itoa(id)})
+}
+func (c *ConstraintController) ByCode(code string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "code", ID: code})
+}
+func (c *ConstraintController) ByUUID(uuid string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "uuid", ID: uuid})
+}
+
+// Constrained params are tried before plain ones and fall through when they don't match
+func TestRouter_ParamConstraints(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&ConstraintController{})
+
+	cases := []struct {
+		path, method, id string
+	}{
+		{"/items/42", "id", "42"},
+		{"/items/-7", "id", "-7"},
+		{"/items/ABC", "code", "ABC"},
+		{"/items/ABCD", "name", "ABCD"},
+		{"/items/widget", "name", "widget"},
+		{"/items/uuid/123e4567-e89b-12d3-a456-426614174000", "uuid", "123e4567-e89b-12d3-a456-426614174000"},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+		if w.Code != HttpStatus.OK {
+			t.Fatalf("%s: want status %d, got %d", tc.path, HttpStatus.OK, w.Code)
+		}
+		tr := decodeTestResponse(t, w)
+		if tr.Method != tc.method || tr.ID != tc.id {
+			t.Errorf("%s: want %s(%q), got %s(%q)", tc.path, tc.method, tc.id, tr.Method, tr.ID)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/items/uuid/not-a-uuid", nil))
+	if w.Code != HttpStatus.NOT_FOUND {
+		t.Errorf("want status %d for invalid uuid, got %d", HttpStatus.NOT_FOUND, w.Code)
+	}
+}
+
+type RepoController struct{}
+
+func (c *RepoController) BasePath() string { return "/repos" }
+func (c *RepoController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/{owner}/{path...}", Handler: "Browse", Name: "repo.browse"},
+		{Method: "GET", Path: "/{owner}/raw/{segments...}", Handler: "Raw"},
+	}
+}
+func (c *RepoController) Browse(owner, path string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: owner, ID: path})
+}
+func (c *RepoController) Raw(owner string, segments []string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: owner, ID: strconv.Itoa(len(segments))})
+}
+
+// Catch-all params capture the rest of the path as a string or []string
+func TestRouter_CatchAll(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&RepoController{})
+
+	cases := []struct {
+		path, method, id string
+	}{
+		{"/repos/isaac/docs/guide/intro.md", "isaac", "docs/guide/intro.md"},
+		{"/repos/isaac/raw/a/b/c", "isaac", "3"},
+		{"/repos/isaac", "isaac", ""},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+		if w.Code != HttpStatus.OK {
+			t.Fatalf("%s: want status %d, got %d", tc.path, HttpStatus.OK, w.Code)
+		}
+		tr := decodeTestResponse(t, w)
+		if tr.Method != tc.method || tr.ID != tc.id {
+			t.Errorf("%s: want %s(%q), got %s(%q)", tc.path, tc.method, tc.id, tr.Method, tr.ID)
+		}
+	}
+}
+
+type UsersByIDController struct{}
+
+func (c *UsersByIDController) BasePath() string { return "/api/users" }
+func (c *UsersByIDController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/{id}", Handler: "Get"}}
+}
+func (c *UsersByIDController) Get(id string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(nil)
+}
+
+type UsersByUserIDController struct{}
+
+func (c *UsersByUserIDController) BasePath() string { return "/api/users" }
+func (c *UsersByUserIDController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/{userId}", Handler: "Find"}}
+}
+func (c *UsersByUserIDController) Find(userId string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(nil)
+}
+
+// Same method and pattern with different param names is a conflict
+func TestRouter_DetectsShadowedRoutes(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+
+	defer func() {
+		msg := fmt.Sprint(recover())
+		for _, want := range []string{
+			"*app.UsersByIDController.Get",
+			"*app.UsersByUserIDController.Find",
+			"/api/users/{userId}",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("want conflict error to mention %q, got %q", want, msg)
+			}
+		}
+	}()
+
+	router.RegisterControllers(&UsersByIDController{}, &UsersByUserIDController{})
+}
+
+type BrokenController struct{}
+
+func (c *BrokenController) BasePath() string { return "/broken" }
+func (c *BrokenController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/", Handler: "Misspelled"},
+		{Method: "GET", Path: "/text", Handler: "Text"},
+		{Method: "GET", Path: "/{id}", Handler: "Channel"},
+	}
+}
+func (c *BrokenController) Text() (string, string)                             { return "no", "pe" }
+func (c *BrokenController) Channel(ch chan int) *ResponseEntity.ResponseEntity { return nil }
+
+// Calling RegisterControllers repeatedly adds to the existing routes
+func TestRouter_RegisterControllersIsAdditive(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&DummyController{})
+	router.RegisterControllers(&PriorityController{})
+
+	for _, path := range []string{"/api/v1/test/", "/files/latest"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != HttpStatus.OK {
+			t.Errorf("%s: want status %d, got %d", path, HttpStatus.OK, w.Code)
+		}
+	}
+}
+
+// RegisterControllersE reports every invalid route and registers nothing
+func TestRouter_RegisterControllersE(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+
+	err := router.RegisterControllersE(&DummyController{}, &BrokenController{})
+	if err == nil {
+		t.Fatal("want error for broken controller, got nil")
+	}
+	for _, want := range []string{"Misspelled", "Text", "chan int"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("want error to mention %q, got %q", want, err.Error())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/test/", nil))
+	if w.Code != HttpStatus.NOT_FOUND {
+		t.Errorf("want no routes registered after error, got status %d", w.Code)
+	}
+}
+
+// URLFor escapes path params and turns the rest into a query string
+func TestRouter_URLFor(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&RepoController{}, &ConstraintController{})
+
+	cases := []struct {
+		name   string
+		params []any
+		want   string
+	}{
+		{"item", []any{"id", 42}, "/items/42"},
+		{"item", []any{"id", 7, "tag", []string{"a", "b"}}, "/items/7?tag=a&tag=b"},
+		{"repo.browse", []any{"owner", "isaac w", "path", "docs/a b.md", "ref", "main"}, "/repos/isaac%20w/docs/a%20b.md?ref=main"},
+	}
+
+	for _, tc := range cases {
+		got, err := router.URLFor(tc.name, tc.params...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, got)
+		}
+	}
+
+	if _, err := router.URLFor("missing"); err == nil {
+		t.Error("want error for unknown route name")
+	}
+	if _, err := router.URLFor("item"); err == nil {
+		t.Error("want error for missing path param")
+	}
+	if _, err := router.URLFor("item", "id", "abc"); err == nil {
+		t.Error("want error for a value breaking the int constraint")
+	}
+	if _, err := router.URLFor("repo.browse", "owner", "isaac", "path", "a/../../etc"); err == nil {
+		t.Error("want error for a catch-all value with .. segments")
+	}
+}
+
+type DuplicateNameController struct{}
+
+func (c *DuplicateNameController) BasePath() string { return "/other" }
+func (c *DuplicateNameController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "Get", Name: "item"}}
+}
+func (c *DuplicateNameController) Get() *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(nil)
+}
+
+// Route names must be unique across controllers
+func TestRouter_DuplicateRouteName(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&ConstraintController{})
+
+	if err := router.RegisterControllersE(&DuplicateNameController{}); err == nil || !strings.Contains(err.Error(), `"item"`) {
+		t.Errorf("want duplicate name error, got %v", err)
+	}
+}
+
+type TenantController struct {
+	types.ControllerBase
+}
+
+func newTenantController() *TenantController {
+	c := &TenantController{}
+	c.WithHost("{tenant}.api.example.com").WithBasePath("/dashboard").WithRoutes([]types.Route{
+		{Method: "GET", Path: "/", Handler: "Get", Name: "tenant.dashboard"},
+		{Method: "GET", Path: "/{id}", Handler: "Find"},
+	})
+	return c
+}
+func (c *TenantController) Get(ctx context.Context) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "tenant", ID: types.PathVar(ctx, "tenant")})
+}
+func (c *TenantController) Find(tenant, id string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: tenant, ID: id})
+}
+
+type AdminController struct {
+	types.ControllerBase
+}
+
+func newAdminController() *AdminController {
+	c := &AdminController{}
+	c.WithHost("admin.example.com").WithBasePath("/dashboard").WithRoutes([]types.Route{
+		{Method: "GET", Path: "/", Handler: "Get"},
+	})
+	return c
+}
+func (c *AdminController) Get() *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "admin"})
+}
+
+type DashboardController struct{}
+
+func (c *DashboardController) BasePath() string { return "/dashboard" }
+func (c *DashboardController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "Get"}}
+}
+func (c *DashboardController) Get() *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: "any"})
+}
+
+// Host-bound controllers are tried before controllers that accept any host
+func TestRouter_HostRouting(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&DashboardController{}, newTenantController(), newAdminController())
+
+	cases := []struct {
+		host, path, method, id string
+	}{
+		{"acme.api.example.com", "/dashboard", "tenant", "acme"},
+		{"ACME.api.example.com:8443", "/dashboard/7", "ACME", "7"},
+		{"admin.example.com", "/dashboard", "admin", ""},
+		{"localhost:8080", "/dashboard", "any", ""},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Host = tc.host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != HttpStatus.OK {
+			t.Fatalf("%s%s: want status %d, got %d", tc.host, tc.path, HttpStatus.OK, w.Code)
+		}
+		tr := decodeTestResponse(t, w)
+		if tr.Method != tc.method || tr.ID != tc.id {
+			t.Errorf("%s%s: want %s(%q), got %s(%q)", tc.host, tc.path, tc.method, tc.id, tr.Method, tr.ID)
+		}
+	}
+
+	got, err := router.URLFor("tenant.dashboard", "tenant", "acme")
+	if err != nil || got != "//acme.api.example.com/dashboard" {
+		t.Errorf("URLFor: want %q, got %q (err %v)", "//acme.api.example.com/dashboard", got, err)
+	}
+}
+
+type DupParamsController struct {
+	types.ControllerBase
+}
+
+func (c *DupParamsController) Get(a, b string) string { return a + "|" + b }
+
+// A parameter name can only be declared once across a route's host and path
+func TestRouter_DuplicateParamNames(t *testing.T) {
+	clearAllGlobalState()
+	for _, tc := range []struct{ host, path string }{
+		{"", "/dup/{id}/x/{id}"},
+		{"{id}.example.com", "/{id}"},
+	} {
+		c := &DupParamsController{}
+		c.WithHost(tc.host).WithBasePath("/p").WithRoutes([]types.Route{
+			{Method: "GET", Path: tc.path, Handler: "Get"},
+		})
+
+		err := NewRouter().RegisterControllersE(c)
+		if err == nil || !strings.Contains(err.Error(), `parameter "id" is declared twice`) {
+			t.Errorf("%s%s: want a duplicate parameter error, got %v", tc.host, tc.path, err)
+		}
+	}
+}
+
+// countingMiddleware counts how many requests pass through it
+type countingMiddleware struct {
+	count int
+}
+
+func (m *countingMiddleware) Func() types.MiddlewareFunc {
+	return func(ctx *types.MiddlewareContext) error {
+		m.count++
+		return ctx.Next()
+	}
+}
+
+// Mounted handlers see the stripped path and still run global middleware
+func TestRouter_MountHandler(t *testing.T) {
+	clearAllGlobalState()
+	counter := &countingMiddleware{}
+	Use(counter)
+
+	router := NewRouter()
+	router.Mount("/internal/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, req.URL.Path)
+	}))
+
+	for path, want := range map[string]string{
+		"/internal/debug/vars": "/debug/vars",
+		"/internal":            "/",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if got := w.Body.String(); got != want {
+			t.Errorf("%s: want stripped path %q, got %q", path, want, got)
+		}
+	}
+
+	if counter.count != 2 {
+		t.Errorf("want global middleware to run twice, ran %d times", counter.count)
+	}
+}
+
+// A mounted handler that panics after writing keeps its partial response,
+// with or without the Recovery middleware
+func TestRouter_MountHandlerPanicAfterWrite(t *testing.T) {
+	for _, withRecovery := range []bool{false, true} {
+		clearAllGlobalState()
+		if withRecovery {
+			Use(middlewares.Recovery)
+		}
+
+		router := NewRouter()
+		router.Mount("/jobs", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(HttpStatus.ACCEPTED)
+			_, _ = io.WriteString(w, "partial")
+			panic("lost the queue")
+		}))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/jobs", nil))
+
+		if w.Code != HttpStatus.ACCEPTED || w.Body.String() != "partial" {
+			t.Errorf("recovery middleware %v: want 202 \"partial\", got %d %q", withRecovery, w.Code, w.Body.String())
+		}
+	}
+}
+
+// A mounted router dispatches its own controllers under the prefix
+func TestRouter_MountRouter(t *testing.T) {
+	clearAllGlobalState()
+	counter := &countingMiddleware{}
+	Use(counter)
+
+	billing := NewRouter()
+	billing.RegisterControllers(&DummyController{})
+
+	router := NewRouter()
+	router.Mount("/billing", billing)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/billing/api/v1/test/abc123", strings.NewReader("{}")))
+
+	if w.Code != HttpStatus.OK {
+		t.Fatalf("want status %d, got %d", HttpStatus.OK, w.Code)
+	}
+	if tr := decodeTestResponse(t, w); tr.ID != "abc123" {
+		t.Errorf("want ID 'abc123', got %q", tr.ID)
+	}
+	if counter.count != 1 {
+		t.Errorf("want global middleware to run once, ran %d times", counter.count)
+	}
+}
+
+type NumberController struct{}
+
+func (c *NumberController) BasePath() string { return "/numbers" }
+func (c *NumberController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/{n}", Handler: "Get"},
+		{Method: "POST", Path: "/", Handler: "Create"},
+	}
+}
+func (c *NumberController) Get(n int) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{ID: strconv.Itoa(n)})
+}
+func (c *NumberController) Create(body TestResponse) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.CREATED).Body(body)
+}
+
+type errorResponse struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Error   map[string]string `json:"error"`
+}
+
+// Binding failures produce a 400 that names the parameter and still reaches post-middleware
+func TestRouter_BindingErrors(t *testing.T) {
+	clearAllGlobalState()
+	counter := &countingMiddleware{}
+	UseAfter(counter)
+
+	router := NewRouter()
+	router.RegisterControllers(&NumberController{})
+
+	cases := []struct {
+		method, path, body, param string
+	}{
+		{"GET", "/numbers/abc", "", "n"},
+		{"POST", "/numbers/", "{not json", "body"},
+		{"POST", "/numbers/", `{"id": 5}`, "body.id"},
+		{"POST", "/numbers/", "", "body"},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+
+		if w.Code != HttpStatus.BAD_REQUEST {
+			t.Fatalf("%s %s: want status %d, got %d", tc.method, tc.path, HttpStatus.BAD_REQUEST, w.Code)
+		}
+		var er errorResponse
+		if err := jsonutil.FromString(w.Body.String(), &er); err != nil {
+			t.Fatalf("%s %s: failed to decode JSON: %v", tc.method, tc.path, err)
+		}
+		if er.Error["parameter"] != tc.param || er.Error["reason"] == "" {
+			t.Errorf("%s %s: want parameter %q with a reason, got %v", tc.method, tc.path, tc.param, er.Error)
+		}
+	}
+
+	if counter.count != len(cases) {
+		t.Errorf("want post-middleware to run %d times, ran %d times", len(cases), counter.count)
+	}
+}
+
+type ListQuery struct {
+	Page int      `query:"page" default:"1" json:"page"`
+	Size *int     `query:"size" json:"size"`
+	Sort string   `query:"sort" default:"name" json:"sort"`
+	Tags []string `query:"tag" json:"tags"`
+}
+
+type ListController struct{}
+
+func (c *ListController) BasePath() string { return "/list" }
+func (c *ListController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "List"}}
+}
+func (c *ListController) List(q ListQuery) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(q)
+}
+
+// Query-tagged struct fields bind from the URL, with defaults, pointers and slices
+func TestRouter_QueryBinding(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&ListController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/list?size=20&tag=a&tag=b", nil))
+
+	var q ListQuery
+	if err := jsonutil.FromString(w.Body.String(), &q); err != nil {
+		t.Fatalf("failed to decode JSON: %v\nRaw body: %s", err, w.Body.String())
+	}
+	if q.Page != 1 || q.Sort != "name" {
+		t.Errorf("want defaults page=1 sort=name, got page=%d sort=%q", q.Page, q.Sort)
+	}
+	if q.Size == nil || *q.Size != 20 {
+		t.Errorf("want size=20, got %v", q.Size)
+	}
+	if strings.Join(q.Tags, ",") != "a,b" {
+		t.Errorf("want tags [a b], got %v", q.Tags)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/list?page=2", nil))
+	if err := jsonutil.FromString(w.Body.String(), &q); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if q.Page != 2 || q.Size != nil {
+		t.Errorf("want page=2 and no size, got page=%d size=%v", q.Page, q.Size)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/list?page=two", nil))
+	if w.Code != HttpStatus.BAD_REQUEST || !strings.Contains(w.Body.String(), "query.page") {
+		t.Errorf("want 400 naming query.page, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+type MeRequest struct {
+	TenantID  string       `header:"X-Tenant-Id,required"`
+	RequestID *string      `header:"X-Request-Id"`
+	Session   *http.Cookie `cookie:"session"`
+	Theme     string       `cookie:"theme" default:"light"`
+}
+
+type SessionController struct{}
+
+func (c *SessionController) BasePath() string { return "/me" }
+func (c *SessionController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "Me"}}
+}
+func (c *SessionController) Me(in MeRequest) *ResponseEntity.ResponseEntity {
+	session := ""
+	if in.Session != nil {
+		session = in.Session.Value
+	}
+	return ResponseEntity.Status(HttpStatus.OK).Body(map[string]any{
+		"tenant":    in.TenantID,
+		"requestId": in.RequestID,
+		"session":   session,
+		"theme":     in.Theme,
+	})
+}
+
+// Header and cookie tags bind request metadata, with required fields enforced
+func TestRouter_HeaderAndCookieBinding(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&SessionController{})
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("X-Tenant-Id", "acme")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var got map[string]any
+	if err := jsonutil.FromString(w.Body.String(), &got); err != nil {
+		t.Fatalf("failed to decode JSON: %v\nRaw body: %s", err, w.Body.String())
+	}
+	want := map[string]any{"tenant": "acme", "requestId": nil, "session": "s3cr3t", "theme": "light"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: want %v, got %v", k, v, got[k])
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/me", nil))
+	if w.Code != HttpStatus.BAD_REQUEST || !strings.Contains(w.Body.String(), "header.X-Tenant-Id") {
+		t.Errorf("want 400 naming header.X-Tenant-Id, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+type UserID int64
+
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level")
+	}
+	return nil
+}
+
+type ScalarQuery struct {
+	Since   time.Time     `query:"since"`
+	Timeout time.Duration `query:"timeout"`
+	Level   Level         `query:"level"`
+	Ratio   float32       `query:"ratio"`
+}
+
+type ScalarController struct{}
+
+func (c *ScalarController) BasePath() string { return "/scalars" }
+func (c *ScalarController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/{id}/{active}/{small}", Handler: "Get"}}
+}
+func (c *ScalarController) Get(id UserID, active bool, small uint8, q ScalarQuery) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(map[string]any{
+		"id":      id,
+		"active":  active,
+		"small":   small,
+		"since":   q.Since.Format(time.DateOnly),
+		"timeout": q.Timeout.String(),
+		"level":   q.Level,
+		"ratio":   q.Ratio,
+	})
+}
+
+// Path and query values convert to every scalar kind, time types and TextUnmarshalers
+func TestRouter_ScalarConversion(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&ScalarController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/scalars/9007199254740993/true/255?since=2024-05-01&timeout=1m30s&level=high&ratio=0.5", nil))
+
+	if w.Code != HttpStatus.OK {
+		t.Fatalf("want status %d, got %d: %s", HttpStatus.OK, w.Code, w.Body.String())
+	}
+	want := `{"active":true,"id":9007199254740993,"level":2,"ratio":0.5,"since":"2024-05-01","small":255,"timeout":"1m30s"}`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	for path, param := range map[string]string{
+		"/scalars/1/true/256":          "small",
+		"/scalars/1/maybe/1":           "active",
+		"/scalars/1/true/1?level=mid":  "query.level",
+		"/scalars/1/true/1?since=soon": "query.since",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != HttpStatus.BAD_REQUEST || !strings.Contains(w.Body.String(), `"parameter":"`+param+`"`) {
+			t.Errorf("%s: want 400 naming %s, got %d %s", path, param, w.Code, w.Body.String())
+		}
+	}
+}
+
+type Principal struct {
+	Name string
+}
+
+type Pageable interface {
+	Page() int
+}
+
+type offsetPage int
+
+func (p offsetPage) Page() int { return int(p) }
+
+type ResolverController struct{}
+
+func (c *ResolverController) BasePath() string { return "/resolved" }
+func (c *ResolverController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/{id}", Handler: "Get"}}
+}
+func (c *ResolverController) Get(p *Principal, req *http.Request, w http.ResponseWriter, page Pageable, id int) *ResponseEntity.ResponseEntity {
+	w.Header().Set("X-Raw-Path", req.URL.Path)
+	return ResponseEntity.Status(HttpStatus.OK).Body(map[string]any{"user": p.Name, "id": id, "page": page.Page()})
+}
+
+// Registered resolvers and the built-in ones inject arguments without consuming path variables
+func TestRouter_ArgumentResolvers(t *testing.T) {
+	clearAllGlobalState()
+	UseResolver(
+		types.ResolverFor(func(ctx *types.MiddlewareContext) (*Principal, error) {
+			return &Principal{Name: ctx.Request.Header.Get("X-User")}, nil
+		}),
+		types.ResolverWhen(
+			func(t reflect.Type) bool { return t == reflect.TypeOf((*Pageable)(nil)).Elem() },
+			func(ctx *types.MiddlewareContext, t reflect.Type) (any, error) {
+				return offsetPage(3), nil
+			},
+		),
+	)
+
+	router := NewRouter()
+	router.RegisterControllers(&ResolverController{})
+
+	req := httptest.NewRequest("GET", "/resolved/42", nil)
+	req.Header.Set("X-User", "isaac")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	want := `{"id":42,"page":3,"user":"isaac"}`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	if got := w.Header().Get("X-Raw-Path"); got != "/resolved/42" {
+		t.Errorf("want X-Raw-Path %q, got %q", "/resolved/42", got)
+	}
+}
+
+type Profile struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Bio   string `json:"bio,omitempty"`
+}
+
+type BodyController struct{}
+
+func (c *BodyController) BasePath() string { return "/profiles" }
+func (c *BodyController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "PATCH", Path: "/{id}", Handler: "Patch"},
+		{Method: "DELETE", Path: "/{id}", Handler: "Delete"},
+		{Method: "PUT", Path: "/", Handler: "Upsert"},
+		{Method: "POST", Path: "/tags", Handler: "Tags"},
+		{Method: "POST", Path: "/meta", Handler: "Meta"},
+	}
+}
+func (c *BodyController) Patch(id int, patch types.MergePatch) *ResponseEntity.ResponseEntity {
+	profile := Profile{Name: "isaac", Email: "isaac@example.com"}
+	if err := patch.ApplyTo(&profile); err != nil {
+		return ResponseEntity.Status(HttpStatus.BAD_REQUEST).Body(err.Error())
+	}
+	return ResponseEntity.Status(HttpStatus.OK).Body(profile)
+}
+func (c *BodyController) Delete(id int, reason *Profile) *ResponseEntity.ResponseEntity {
+	if reason == nil {
+		return ResponseEntity.Status(HttpStatus.OK).Body("no body")
+	}
+	return ResponseEntity.Status(HttpStatus.OK).Body(reason.Bio)
+}
+func (c *BodyController) Upsert(p *Profile) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(p)
+}
+func (c *BodyController) Tags(tags []string) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(len(tags))
+}
+func (c *BodyController) Meta(meta map[string]int) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(meta["count"])
+}
+
+// Bodies bind on any method that carries one, into pointers, slices, maps and merge patches
+func TestRouter_BodyBinding(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&BodyController{})
+
+	cases := []struct {
+		method, path, body, want string
+	}{
+		{"PATCH", "/profiles/1", `{"email":null,"bio":"gopher"}`, `{"name":"isaac","bio":"gopher"}`},
+		{"PATCH", "/profiles/1", "", `{"name":"isaac","email":"isaac@example.com"}`},
+		{"DELETE", "/profiles/1", `{"bio":"spam"}`, `"spam"`},
+		{"DELETE", "/profiles/1", "", `"no body"`},
+		{"PUT", "/profiles/", `{"name":"ada"}`, `{"name":"ada"}`},
+		{"PUT", "/profiles/", "", `null`},
+		{"POST", "/profiles/tags", `["a","b","c"]`, `3`},
+		{"POST", "/profiles/meta", `{"count":7}`, `7`},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.method == "PATCH" {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != HttpStatus.OK {
+			t.Fatalf("%s %s: want status %d, got %d: %s", tc.method, tc.path, HttpStatus.OK, w.Code, w.Body.String())
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+			t.Errorf("%s %s %s: want %s, got %s", tc.method, tc.path, tc.body, tc.want, got)
+		}
+	}
+}
+
+type SignupRequest struct {
+	Email string   `json:"email" validate:"required,email"`
+	Name  string   `json:"name" validate:"min=3"`
+	Tags  []string `json:"tags" validate:"max=2"`
+}
+
+type SignupController struct{}
+
+func (c *SignupController) BasePath() string { return "/signup" }
+func (c *SignupController) Routes() []types.Route {
+	return []types.Route{{Method: "POST", Path: "/", Handler: "Create"}}
+}
+func (c *SignupController) Create(req *SignupRequest) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.CREATED).Body(req)
+}
+
+// Invalid bodies are rejected with a 422 listing every failing field
+func TestRouter_Validation(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&SignupController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/signup/", strings.NewReader(`{"email":"nope","name":"al","tags":["a","b","c"]}`)))
+
+	if w.Code != HttpStatus.UNPROCESSABLE_ENTITY {
+		t.Fatalf("want status %d, got %d", HttpStatus.UNPROCESSABLE_ENTITY, w.Code)
+	}
+	var body struct {
+		Error []struct {
+			Field string `json:"field"`
+			Rule  string `json:"rule"`
+		} `json:"error"`
+	}
+	if err := jsonutil.FromString(w.Body.String(), &body); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	var got []string
+	for _, fe := range body.Error {
+		got = append(got, fe.Field+":"+fe.Rule)
+	}
+	if want := []string{"email:email", "name:min", "tags:max"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/signup/", strings.NewReader(`{"email":"isaac@example.com","name":"isaac"}`)))
+	if w.Code != HttpStatus.CREATED {
+		t.Errorf("want status %d, got %d: %s", HttpStatus.CREATED, w.Code, w.Body.String())
+	}
+}
+
+type WebhookController struct{}
+
+func (c *WebhookController) BasePath() string { return "/hooks" }
+func (c *WebhookController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "POST", Path: "/public", Handler: "Receive"},
+		{Method: "POST", Path: "/internal", Handler: "Receive", Decode: &types.DecodeOptions{}},
+		{Method: "POST", Path: "/raw", Handler: "Raw"},
+	}
+}
+func (c *WebhookController) Receive(body TestResponse) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(body)
+}
+func (c *WebhookController) Raw(body map[string]any) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(fmt.Sprintf("%T", body["amount"]))
+}
+
+// Router-level decoding options are strict, and a route can opt back into lenient decoding
+func TestRouter_DecodeOptions(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.SetDecodeOptions(types.DecodeOptions{
+		MaxBodyBytes:          64,
+		DisallowUnknownFields: true,
+		DisallowTrailingData:  true,
+		UseNumber:             true,
+	})
+	router.RegisterControllers(&WebhookController{})
+
+	cases := []struct {
+		path, body, param string
+		status            int
+	}{
+		{"/hooks/public", `{"method":"POST"}`, "", HttpStatus.OK},
+		{"/hooks/public", `{"method":"POST","extra":1}`, "body.extra", HttpStatus.BAD_REQUEST},
+		{"/hooks/public", `{"method":"POST"} {"method":"PUT"}`, "body", HttpStatus.BAD_REQUEST},
+		{"/hooks/public", `{"method":"` + strings.Repeat("x", 100) + `"}`, "body", HttpStatus.REQUEST_ENTITY_TOO_LARGE},
+		{"/hooks/internal", `{"method":"POST","extra":1} trailing`, "", HttpStatus.OK},
+		{"/hooks/internal", `{"method":"` + strings.Repeat("x", 100) + `"}`, "", HttpStatus.OK},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body)))
+
+		if w.Code != tc.status {
+			t.Fatalf("%s %s: want status %d, got %d: %s", tc.path, tc.body, tc.status, w.Code, w.Body.String())
+		}
+		if tc.param == "" {
+			continue
+		}
+		var er errorResponse
+		if err := jsonutil.FromString(w.Body.String(), &er); err != nil {
+			t.Fatalf("%s: failed to decode JSON: %v", tc.path, err)
+		}
+		if er.Error["parameter"] != tc.param {
+			t.Errorf("%s %s: want parameter %q, got %v", tc.path, tc.body, tc.param, er.Error)
+		}
+	}
+
+	// Without a Content-Length, the limit is enforced while reading
+	req := httptest.NewRequest("POST", "/hooks/public", io.MultiReader(strings.NewReader(`{"method":"`+strings.Repeat("x", 100)+`"}`)))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != HttpStatus.REQUEST_ENTITY_TOO_LARGE {
+		t.Errorf("want status %d for a chunked body, got %d", HttpStatus.REQUEST_ENTITY_TOO_LARGE, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/hooks/raw", strings.NewReader(`{"amount":12345678901234567890}`)))
+	if got := strings.TrimSpace(w.Body.String()); got != `"json.Number"` {
+		t.Errorf("want UseNumber to decode json.Number, got %s", got)
+	}
+}
+
+type UploadForm struct {
+	Title       string                  `form:"title,required"`
+	Pages       int                     `form:"pages" default:"1"`
+	Document    *multipart.FileHeader   `form:"document"`
+	Attachments []*multipart.FileHeader `form:"attachment"`
+}
+
+type UploadController struct{}
+
+func (c *UploadController) BasePath() string { return "/uploads" }
+func (c *UploadController) Routes() []types.Route {
+	return []types.Route{{Method: "POST", Path: "/", Handler: "Upload"}}
+}
+func (c *UploadController) Upload(form UploadForm) *ResponseEntity.ResponseEntity {
+	result := map[string]any{"title": form.Title, "pages": form.Pages, "attachments": len(form.Attachments)}
+	if form.Document != nil {
+		f, err := form.Document.Open()
+		if err != nil {
+			return ResponseEntity.Status(HttpStatus.INTERNAL_SERVER_ERR).Body(err.Error())
+		}
+		defer f.Close()
+		content, _ := io.ReadAll(f)
+		result["document"] = form.Document.Filename + ":" + string(content)
+	}
+	return ResponseEntity.Status(HttpStatus.OK).Body(result)
+}
+
+// URL-encoded and multipart bodies bind into form-tagged fields, files included
+func TestRouter_FormBinding(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.SetDecodeOptions(types.DecodeOptions{MaxMemory: 16})
+	router.RegisterControllers(&UploadController{})
+
+	req := httptest.NewRequest("POST", "/uploads/", strings.NewReader("title=report&pages=3"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if want := `{"attachments":0,"pages":3,"title":"report"}`; strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("url-encoded: want %s, got %s", want, w.Body.String())
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("title", "scan")
+	doc, _ := mw.CreateFormFile("document", "scan.txt")
+	_, _ = doc.Write([]byte(strings.Repeat("a", 64))) // Larger than MaxMemory, so it spills to disk
+	for _, name := range []string{"a.txt", "b.txt"} {
+		part, _ := mw.CreateFormFile("attachment", name)
+		_, _ = part.Write([]byte(name))
+	}
+	_ = mw.Close()
+
+	req = httptest.NewRequest("POST", "/uploads/", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	want := `{"attachments":2,"document":"scan.txt:` + strings.Repeat("a", 64) + `","pages":1,"title":"scan"}`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("multipart: want %s, got %s", want, got)
+	}
+
+	req = httptest.NewRequest("POST", "/uploads/", strings.NewReader("pages=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var er errorResponse
+	_ = jsonutil.FromString(w.Body.String(), &er)
+	if w.Code != HttpStatus.BAD_REQUEST || er.Error["parameter"] != "form.title" {
+		t.Errorf("want 400 for form.title, got %d %v", w.Code, er.Error)
+	}
+}
+
+// A form body sent to a struct without form fields is refused, not silently dropped
+func TestRouter_FormBodyWithoutFormFields(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&NumberController{})
+
+	req := httptest.NewRequest("POST", "/numbers/", strings.NewReader("id=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != HttpStatus.UNSUPPORTED_MEDIA_TYPE {
+		t.Errorf("want status %d, got %d %s", HttpStatus.UNSUPPORTED_MEDIA_TYPE, w.Code, w.Body.String())
+	}
+}
+
+// URL-encoded bodies are capped at 10 MiB even when MaxBodyBytes isn't set
+func TestRouter_FormBodyDefaultLimit(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&UploadController{})
+
+	req := httptest.NewRequest("POST", "/uploads/", strings.NewReader("title="+strings.Repeat("a", 10<<20)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != HttpStatus.REQUEST_ENTITY_TOO_LARGE {
+		t.Errorf("want status %d, got %d", HttpStatus.REQUEST_ENTITY_TOO_LARGE, w.Code)
+	}
+}
+
+type NamedParamsController struct{}
+
+func (c *NamedParamsController) BasePath() string { return "/named" }
+func (c *NamedParamsController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "PUT", Path: "/users/{userid}", Handler: "Put"},
+		{Method: "GET", Path: "/{a}/minus/{b}", Handler: "Minus", Params: []string{"b", "", "a"}},
+	}
+}
+func (c *NamedParamsController) Put(body TestResponse, userid int) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(TestResponse{Method: body.Method, ID: strconv.Itoa(userid)})
+}
+func (c *NamedParamsController) Minus(b int, req *http.Request, a int) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(a - b)
+}
+
+type BadParamsController struct{}
+
+func (c *BadParamsController) BasePath() string { return "/bad" }
+func (c *BadParamsController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/{id}", Handler: "Get", Params: []string{"userid"}},
+		{Method: "PUT", Path: "/{id}", Handler: "Put", Params: []string{"id"}},
+		{Method: "GET", Path: "/two/{a}/{b}", Handler: "Two", Params: []string{"a"}},
+	}
+}
+func (c *BadParamsController) Two(a, b string) string { return a + b }
+func (c *BadParamsController) Get(id int) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(id)
+}
+func (c *BadParamsController) Put(body TestResponse) *ResponseEntity.ResponseEntity {
+	return ResponseEntity.Status(HttpStatus.OK).Body(body)
+}
+
+// Path variables bind by name: body structs don't consume them, and Params can reorder them
+func TestRouter_NamedPathParams(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&NamedParamsController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/named/users/42", strings.NewReader(`{"method":"PUT"}`)))
+	tr := decodeTestResponse(t, w)
+	if tr.ID != "42" || tr.Method != "PUT" {
+		t.Errorf("want body PUT with ID 42, got %+v", tr)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/named/10/minus/3", nil))
+	if got := strings.TrimSpace(w.Body.String()); got != "7" {
+		t.Errorf("want 7, got %s", got)
+	}
+
+	err := NewRouter().RegisterControllersE(&BadParamsController{})
+	if err == nil {
+		t.Fatal("want an error for invalid params")
+	}
+	for _, want := range []string{
+		`path has no variable "userid"`,
+		`cannot bind path variable "id" to type app.TestResponse`,
+		`parameter 1: no entry in params`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("want error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+type ErrorsController struct{}
+
+func (c *ErrorsController) BasePath() string { return "/errors" }
+func (c *ErrorsController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/entity/{id}", Handler: "Entity"},
+		{Method: "GET", Path: "/value/{id}", Handler: "Value"},
+		{Method: "DELETE", Path: "/{id}", Handler: "Delete"},
+		{Method: "GET", Path: "/typed/{id}", Handler: "Typed"},
+	}
+}
+func (c *ErrorsController) find(id int) (TestResponse, error) {
+	switch id {
+	case 1:
+		return TestResponse{ID: "1"}, nil
+	case 2:
+		return TestResponse{}, fmt.Errorf("loading user 2: %w", exception.NewHTTPError(HttpStatus.NOT_FOUND, "user not found"))
+	}
+	return TestResponse{}, errors.New("connection refused: db.internal:5432")
+}
+func (c *ErrorsController) Entity(id int) (*ResponseEntity.ResponseEntity, error) {
+	tr, err := c.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return ResponseEntity.Status(HttpStatus.CREATED).Body(tr), nil
+}
+func (c *ErrorsController) Value(id int) (TestResponse, error) {
+	return c.find(id)
+}
+func (c *ErrorsController) Delete(id int) error {
+	_, err := c.find(id)
+	return err
+}
+func (c *ErrorsController) Typed(id int) (TestResponse, error) {
+	var err *exception.HTTPError // A nil *HTTPError in a non-nil error interface
+	if id == 2 {
+		err = exception.NewHTTPError(HttpStatus.NOT_FOUND, "user not found")
+	}
+	return TestResponse{ID: strconv.Itoa(id)}, err
+}
+
+// Returned errors are mapped to responses: typed ones keep their status, others become a bare 500
+func TestRouter_ErrorReturns(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&ErrorsController{})
+
+	cases := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/errors/entity/1", HttpStatus.CREATED, `"id":"1"`},
+		{"GET", "/errors/entity/2", HttpStatus.NOT_FOUND, `"message":"user not found"`},
+		{"GET", "/errors/value/1", HttpStatus.OK, `"id":"1"`},
+		{"GET", "/errors/value/3", HttpStatus.INTERNAL_SERVER_ERR, `"message":"Internal server error"`},
+		{"DELETE", "/errors/1", HttpStatus.NO_CONTENT, ``},
+		{"DELETE", "/errors/2", HttpStatus.NOT_FOUND, `"status":404`},
+		{"GET", "/errors/typed/1", HttpStatus.OK, `"id":"1"`},
+		{"GET", "/errors/typed/2", HttpStatus.NOT_FOUND, `"message":"user not found"`},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: want status %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("%s %s: want body containing %s, got %s", tc.method, tc.path, tc.body, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "db.internal") {
+			t.Errorf("%s %s: internal error detail leaked: %s", tc.method, tc.path, w.Body.String())
+		}
+	}
+}
+
+type PlainController struct{}
+
+func (c *PlainController) BasePath() string { return "/plain" }
+func (c *PlainController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "POST", Path: "/", Handler: "Create", Status: HttpStatus.CREATED},
+		{Method: "GET", Path: "/names", Handler: "Names"},
+		{Method: "GET", Path: "/greeting", Handler: "Greeting"},
+		{Method: "GET", Path: "/{id}", Handler: "Find"},
+		{Method: "POST", Path: "/ping", Handler: "Ping"},
+		{Method: "POST", Path: "/jobs", Handler: "Enqueue", Status: HttpStatus.ACCEPTED},
+	}
+}
+func (c *PlainController) Create(body TestResponse) TestResponse { return body }
+func (c *PlainController) Names() []string                       { return []string{"ada", "grace"} }
+func (c *PlainController) Greeting() string                      { return "hello" }
+func (c *PlainController) Find(id int) *TestResponse {
+	if id != 1 {
+		return nil
+	}
+	return &TestResponse{ID: "1"}
+}
+func (c *PlainController) Ping()          {}
+func (c *PlainController) Enqueue() error { return nil }
+
+// Plain return values are wrapped in a response with the route's status
+func TestRouter_PlainReturnValues(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&PlainController{})
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/plain/", `{"method":"POST"}`, HttpStatus.CREATED, `{"method":"POST"}`},
+		{"GET", "/plain/names", "", HttpStatus.OK, `["ada","grace"]`},
+		{"GET", "/plain/greeting", "", HttpStatus.OK, `"hello"`},
+		{"GET", "/plain/1", "", HttpStatus.OK, `{"method":"","id":"1"}`},
+		{"GET", "/plain/2", "", HttpStatus.NO_CONTENT, ``},
+		{"POST", "/plain/ping", "", HttpStatus.NO_CONTENT, ``},
+		{"POST", "/plain/jobs", "", HttpStatus.ACCEPTED, ``},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: want status %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+			t.Errorf("%s %s: want body %s, got %s", tc.method, tc.path, tc.want, got)
+		}
+	}
+}
+
+var errUserNotFound = errors.New("user not found")
+
+type quotaError struct {
+	Limit int
+}
+
+func (e *quotaError) Error() string { return fmt.Sprintf("quota of %d exceeded", e.Limit) }
+
+type AdviceController struct{}
+
+func (c *AdviceController) BasePath() string { return "/advice" }
+func (c *AdviceController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "GET", Path: "/missing", Handler: "Missing"},
+		{Method: "GET", Path: "/quota", Handler: "Quota"},
+		{Method: "GET", Path: "/teapot", Handler: "Teapot"},
+	}
+}
+func (c *AdviceController) ErrorHandlers() []types.ErrorHandler {
+	return []types.ErrorHandler{
+		types.OnErrorAs(func(ctx *types.MiddlewareContext, err *quotaError) *ResponseEntity.ResponseEntity {
+			return ResponseEntity.Status(HttpStatus.TOO_MANY_REQUESTS).Body(map[string]int{"limit": err.Limit})
+		}),
+	}
+}
+func (c *AdviceController) Missing() (TestResponse, error) {
+	return TestResponse{}, fmt.Errorf("loading profile: %w", errUserNotFound)
+}
+func (c *AdviceController) Quota() error   { return &quotaError{Limit: 10} }
+func (c *AdviceController) Teapot() string { return "unreachable" }
+
+type failingMiddleware struct{}
+
+func (m *failingMiddleware) Func() types.MiddlewareFunc {
+	return func(ctx *types.MiddlewareContext) error {
+		if strings.HasSuffix(ctx.Request.URL.Path, "/teapot") {
+			return fmt.Errorf("no teapot for this user: %w", errUserNotFound)
+		}
+		return ctx.Next()
+	}
+}
+
+// Errors from handlers and middleware go through controller, then router error handlers,
+// so the controller's quotaError handler wins over the router's
+func TestRouter_ErrorHandlers(t *testing.T) {
+	clearAllGlobalState()
+	Use(&failingMiddleware{})
+
+	router := NewRouter()
+	router.UseErrorHandler(
+		types.OnError(errUserNotFound, func(ctx *types.MiddlewareContext, err error) *ResponseEntity.ResponseEntity {
+			return exception.NotFoundException(err.Error())
+		}),
+		types.OnErrorAs(func(ctx *types.MiddlewareContext, err *quotaError) *ResponseEntity.ResponseEntity {
+			return ResponseEntity.Status(HttpStatus.IM_A_TEAPOT).Body(err.Limit)
+		}),
+	)
+	router.RegisterControllers(&AdviceController{})
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/advice/missing", HttpStatus.NOT_FOUND, `"message":"loading profile: user not found"`},
+		{"/advice/quota", HttpStatus.TOO_MANY_REQUESTS, `{"limit":10}`},
+		{"/advice/teapot", HttpStatus.NOT_FOUND, `"message":"no teapot for this user: user not found"`},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+		if w.Code != tc.status {
+			t.Errorf("%s: want status %d, got %d", tc.path, tc.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("%s: want body containing %s, got %s", tc.path, tc.body, w.Body.String())
+		}
+	}
+}
+
+type denyConfig struct {
+	Suffix string
+}
+
+// Errors from builder middleware reach the router's error handlers too, named
+// after the middleware that returned them
+func TestRouter_BuilderMiddlewareErrors(t *testing.T) {
+	clearAllGlobalState()
+	Use(types.NewMiddlewareBuilder("outer", &denyConfig{}, func(ctx *types.MiddlewareContext, config *denyConfig) error {
+		return ctx.Next()
+	}))
+	Use(types.NewMiddlewareBuilder("deny", &denyConfig{Suffix: "/teapot"}, func(ctx *types.MiddlewareContext, config *denyConfig) error {
+		if strings.HasSuffix(ctx.Request.URL.Path, config.Suffix) {
+			return fmt.Errorf("denied: %w", errUserNotFound)
+		}
+		return ctx.Next()
+	}))
+
+	router := NewRouter()
+	router.UseErrorHandler(types.OnError(errUserNotFound, func(ctx *types.MiddlewareContext, err error) *ResponseEntity.ResponseEntity {
+		return exception.NotFoundException(err.Error())
+	}))
+	router.RegisterControllers(&AdviceController{})
+
+	for path, body := range map[string]string{
+		"/advice/teapot":  `"message":"middleware 'deny': denied: user not found"`,
+		"/advice/missing": `"message":"loading profile: user not found"`,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != HttpStatus.NOT_FOUND || !strings.Contains(w.Body.String(), body) {
+			t.Errorf("%s: want 404 with %s, got %d %s", path, body, w.Code, w.Body.String())
+		}
+	}
+}
+
+type PanicController struct{}
+
+func (c *PanicController) BasePath() string { return "/panic" }
+func (c *PanicController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/{id}", Handler: "Get"}}
+}
+func (c *PanicController) Get(id int) *TestResponse {
+	var tr *TestResponse
+	if id == 1 {
+		tr = &TestResponse{ID: "1"}
+	}
+	tr.Method = "GET" // nil pointer dereference unless id is 1
+	return tr
+}
+
+// A panicking handler is answered with a 500 and reported, and the router keeps serving
+func TestRouter_PanicRecovery(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	var reported any
+	router.OnPanic(func(ctx *types.MiddlewareContext, recovered any, stack []byte) {
+		reported = recovered
+	})
+	router.RegisterControllers(&PanicController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/panic/2", nil))
+	if w.Code != HttpStatus.INTERNAL_SERVER_ERR {
+		t.Errorf("want status %d, got %d", HttpStatus.INTERNAL_SERVER_ERR, w.Code)
+	}
+	if err, ok := reported.(error); !ok || !strings.Contains(err.Error(), "nil pointer") {
+		t.Errorf("want the nil pointer panic reported, got %v", reported)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/panic/1", nil))
+	if w.Code != HttpStatus.OK {
+		t.Errorf("want status %d after a panic, got %d", HttpStatus.OK, w.Code)
+	}
+}
+
+// With Problem Details on, router errors are application/problem+json naming the request path
+func TestRouter_ProblemDetails(t *testing.T) {
+	clearAllGlobalState()
+	exception.UseProblemDetails(true)
+	defer exception.UseProblemDetails(false)
+
+	router := NewRouter()
+	router.RegisterControllers(&NumberController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/numbers/abc", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("want application/problem+json, got %q", ct)
+	}
+	var problem map[string]any
+	if err := jsonutil.FromString(w.Body.String(), &problem); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if problem["status"] != float64(400) || problem["instance"] != "/numbers/abc" || problem["parameter"] != "n" {
+		t.Errorf("want a 400 problem for parameter n at /numbers/abc, got %v", problem)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/nowhere", nil))
+	if w.Code != HttpStatus.NOT_FOUND || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("want a 404 problem, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+var maintenanceProblem = exception.NewProblem(HttpStatus.SERVICE_UNAVAILABLE, "down for maintenance").Response()
+
+type MaintenanceController struct{}
+
+func (c *MaintenanceController) BasePath() string { return "/maintenance" }
+func (c *MaintenanceController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/{page}", Handler: "Get"}}
+}
+func (c *MaintenanceController) Get(page string) *ResponseEntity.ResponseEntity {
+	return maintenanceProblem
+}
+
+// A problem shared between requests gets each request's path, and isn't changed itself
+func TestRouter_SharedProblem(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&MaintenanceController{})
+
+	for _, path := range []string{"/maintenance/a", "/maintenance/b"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		var problem map[string]any
+		if err := jsonutil.FromString(w.Body.String(), &problem); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if w.Code != HttpStatus.SERVICE_UNAVAILABLE || problem["instance"] != path {
+			t.Errorf("want a 503 problem at %s, got %d %v", path, w.Code, problem)
+		}
+	}
+	if instance := maintenanceProblem.BodyData.(*exception.Problem).Instance; instance != "" {
+		t.Errorf("want the shared problem left alone, got instance %q", instance)
+	}
+}
+
+type StreamController struct{}
+
+func (c *StreamController) BasePath() string { return "/stream" }
+func (c *StreamController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "Events"}}
+}
+func (c *StreamController) Events(w http.ResponseWriter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i := 1; i <= 2; i++ {
+		fmt.Fprintf(w, "data: %d\n\n", i)
+		flusher.Flush()
+	}
+	if _, ok := w.(http.Hijacker); !ok {
+		fmt.Fprint(w, "data: no hijacker\n\n")
+	}
+}
+
+// The writer handed to handlers keeps http.Flusher and http.Hijacker
+func TestRouter_StreamingWriter(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&StreamController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stream/", nil))
+
+	if w.Code != HttpStatus.OK || !w.Flushed {
+		t.Errorf("want a flushed 200, got %d (flushed %v)", w.Code, w.Flushed)
+	}
+	if want := "data: 1\n\ndata: 2\n\n"; w.Body.String() != want {
+		t.Errorf("want %q, got %q", want, w.Body.String())
+	}
+}
+
+type SearchQ struct {
+	Q     string `query:"q"`
+	Lang  string `header:"Accept-Language"`
+	Cache bool   `json:"-"`
+}
+
+type PBody struct {
+	Name string `json:"name"`
+}
+
+type MixedQ struct {
+	Page int    `query:"page"`
+	Name string `json:"name"`
+}
+
+type TagOnlyController struct{}
+
+func (c *TagOnlyController) BasePath() string { return "/tagonly" }
+func (c *TagOnlyController) Routes() []types.Route {
+	return []types.Route{
+		{Method: "POST", Path: "/search", Handler: "Search"},
+		{Method: "POST", Path: "/items", Handler: "Create"},
+		{Method: "GET", Path: "/mixed", Handler: "Mixed"},
+		{Method: "GET", Path: "/plain", Handler: "Plain"},
+	}
+}
+func (c *TagOnlyController) Mixed(q *MixedQ) int      { return q.Page }
+func (c *TagOnlyController) Plain(b *PBody) *PBody    { return b }
+func (c *TagOnlyController) Search(q SearchQ) SearchQ { return q }
+func (c *TagOnlyController) Create(q ListQuery, body PBody) map[string]any {
+	return map[string]any{"page": q.Page, "name": body.Name}
+}
+
+type TwoBodiesController struct{}
+
+func (c *TwoBodiesController) BasePath() string { return "/twobodies" }
+func (c *TwoBodiesController) Routes() []types.Route {
+	return []types.Route{{Method: "POST", Path: "/", Handler: "Create"}}
+}
+func (c *TwoBodiesController) Create(a PBody, b map[string]any) PBody { return a }
+
+// Structs made only of tagged fields bind from their tags, leaving the body to the real body argument
+func TestRouter_TagOnlyStructs(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&TagOnlyController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/tagonly/search?q=go", nil))
+	if got := strings.TrimSpace(w.Body.String()); w.Code != HttpStatus.OK || got != `{"Q":"go","Lang":""}` {
+		t.Errorf("want 200 with q bound from the query, got %d %s", w.Code, got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/tagonly/items?page=4", strings.NewReader(`{"name":"widget"}`)))
+	if got := strings.TrimSpace(w.Body.String()); got != `{"name":"widget","page":4}` {
+		t.Errorf("want the body in its own argument, got %d %s", w.Code, got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tagonly/mixed?page=3", nil))
+	if got := strings.TrimSpace(w.Body.String()); w.Code != HttpStatus.OK || got != "3" {
+		t.Errorf("want the pointer allocated with page bound, got %d %s", w.Code, got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tagonly/plain", nil))
+	if w.Code != HttpStatus.NO_CONTENT {
+		t.Errorf("want a nil pointer without tagged fields, got %d %s", w.Code, w.Body.String())
+	}
+
+	err := NewRouter().RegisterControllersE(&TwoBodiesController{})
+	if err == nil || !strings.Contains(err.Error(), "read the request body") {
+		t.Errorf("want an error for two body arguments, got %v", err)
+	}
+}
+
+// Only structs with JSON fields insist on a body; tag-only ones and pointers don't
+func TestRouter_EmptyBodyRule(t *testing.T) {
+	clearAllGlobalState()
+	router := NewRouter()
+	router.RegisterControllers(&TagOnlyController{}, &NumberController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/tagonly/search?q=rust", strings.NewReader("")))
+	if w.Code != HttpStatus.OK {
+		t.Errorf("tag-only struct: want status %d, got %d", HttpStatus.OK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/numbers/", strings.NewReader("")))
+	if w.Code != HttpStatus.BAD_REQUEST {
+		t.Errorf("struct with JSON fields: want status %d, got %d", HttpStatus.BAD_REQUEST, w.Code)
+	}
+}
+
+type Thing struct {
+	Name string
+}
+
+type WrongResolverController struct{}
+
+func (c *WrongResolverController) BasePath() string { return "/wrong" }
+func (c *WrongResolverController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "Get"}}
+}
+func (c *WrongResolverController) Get(thing *Thing) string { return thing.Name }
+
+// A resolver returning the wrong type is reported as a server error, not a reflect panic
+func TestRouter_ResolverTypeMismatch(t *testing.T) {
+	clearAllGlobalState()
+	var panicked bool
+	UseResolver(types.ResolverWhen(
+		func(t reflect.Type) bool { return t == reflect.TypeOf(&Thing{}) },
+		func(ctx *types.MiddlewareContext, t reflect.Type) (any, error) { return "not a thing", nil },
+	))
+
+	router := NewRouter()
+	router.OnPanic(func(ctx *types.MiddlewareContext, recovered any, stack []byte) { panicked = true })
+	router.RegisterControllers(&WrongResolverController{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/wrong/", nil))
+
+	if w.Code != HttpStatus.INTERNAL_SERVER_ERR || panicked {
+		t.Errorf("want a 500 without a panic, got %d (panicked %v)", w.Code, panicked)
+	}
+	_, err := types.ResolverWhen(
+		func(reflect.Type) bool { return true },
+		func(*types.MiddlewareContext, reflect.Type) (any, error) { return "not a thing", nil },
+	).Resolve(nil, reflect.TypeOf(&Thing{}))
+	if err == nil || !strings.Contains(err.Error(), "*app.Thing") || !strings.Contains(err.Error(), "string") {
+		t.Errorf("want an error naming both types, got %v", err)
+	}
+}
+
+type SecureController struct{}
+
+func (c *SecureController) BasePath() string { return "/secure" }
+func (c *SecureController) Routes() []types.Route {
+	return []types.Route{{Method: "GET", Path: "/", Handler: "Get"}}
+}
+func (c *SecureController) Get(p *Principal) string { return p.Name }
+
+// Resolver errors are answered like handler errors: typed ones keep their
+// status, others become a bare 500
+func TestRouter_ResolverErrors(t *testing.T) {
+	clearAllGlobalState()
+	UseResolver(types.ResolverFor(func(ctx *types.MiddlewareContext) (*Principal, error) {
+		switch ctx.Request.Header.Get("Authorization") {
+		case "":
+			return nil, exception.NewHTTPError(HttpStatus.UNAUTHORIZED, "login required")
+		case "broken":
+			return nil, errors.New("pq: connection refused to 10.0.0.5:5432")
+		}
+		return &Principal{Name: "ada"}, nil
+	}))
+
+	router := NewRouter()
+	router.RegisterControllers(&SecureController{})
+
+	cases := []struct {
+		auth   string
+		status int
+		body   string
+	}{
+		{"", HttpStatus.UNAUTHORIZED, `"message":"login required"`},
+		{"broken", HttpStatus.INTERNAL_SERVER_ERR, `"message":"Internal server error"`},
+		{"token", HttpStatus.OK, `"ada"`},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/secure/", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("auth %q: want %d with %s, got %d %s", tc.auth, tc.status, tc.body, w.Code, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "10.0.0.5") {
+			t.Errorf("auth %q: internal error detail leaked: %s", tc.auth, w.Body.String())
+		}
+	}
+}
+
+type TypoRequest struct {
+	Name string `json:"name" validate:"requird"`
+}
+
+type TypoRuleController struct{}
+
+func (c *TypoRuleController) BasePath() string { return "/typo" }
+func (c *TypoRuleController) Routes() []types.Route {
+	return []types.Route{{Method: "POST", Path: "/", Handler: "Create"}}
+}
+func (c *TypoRuleController) Create(req TypoRequest) TypoRequest { return req }
+
+// A misspelled validation rule is a programmer error, caught at registration
+func TestRouter_UnknownValidationRule(t *testing.T) {
+	clearAllGlobalState()
+	err := NewRouter().RegisterControllersE(&TypoRuleController{})
+	if err == nil || !strings.Contains(err.Error(), `unknown validation rule "requird"`) {
+		t.Errorf("want a registration error for the unknown rule, got %v", err)
+	}
+}
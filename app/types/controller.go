@@ -6,10 +6,12 @@ type Controller interface {
 }
 
 type ControllerBase struct {
+	host           string
 	basePath       string
 	routes         []Route
 	preMiddleware  []Middleware
 	postMiddleware []Middleware
+	errorHandlers  []ErrorHandler
 }
 
 // WithBasePath sets the base path for the controller (e.g. "/api/users")
@@ -18,6 +20,13 @@ func (c *ControllerBase) WithBasePath(path string) *ControllerBase {
 	return c
 }
 
+// WithHost restricts the controller to requests for a host pattern
+// (e.g. "admin.example.com" or "{tenant}.api.example.com")
+func (c *ControllerBase) WithHost(host string) *ControllerBase {
+	c.host = host
+	return c
+}
+
 // WithRoutes adds the route list for this controller
 func (c *ControllerBase) WithRoutes(routes []Route) *ControllerBase {
 	c.routes = routes
@@ -36,8 +45,17 @@ func (c *ControllerBase) UseAfter(mw ...Middleware) *ControllerBase {
 	return c
 }
 
+// UseErrorHandler adds error handlers for this controller's routes, tried
+// before the router's
+func (c *ControllerBase) UseErrorHandler(handlers ...ErrorHandler) *ControllerBase {
+	c.errorHandlers = append(c.errorHandlers, handlers...)
+	return c
+}
+
 // Required interface implementations
-func (c *ControllerBase) BasePath() string             { return c.basePath }
-func (c *ControllerBase) Routes() []Route              { return c.routes }
-func (c *ControllerBase) PreMiddleware() []Middleware  { return c.preMiddleware }
-func (c *ControllerBase) PostMiddleware() []Middleware { return c.postMiddleware }
+func (c *ControllerBase) Host() string                  { return c.host }
+func (c *ControllerBase) BasePath() string              { return c.basePath }
+func (c *ControllerBase) Routes() []Route               { return c.routes }
+func (c *ControllerBase) PreMiddleware() []Middleware   { return c.preMiddleware }
+func (c *ControllerBase) PostMiddleware() []Middleware  { return c.postMiddleware }
+func (c *ControllerBase) ErrorHandlers() []ErrorHandler { return c.errorHandlers }
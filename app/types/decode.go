@@ -0,0 +1,13 @@
+package types
+
+// DecodeOptions controls how request bodies are decoded. The zero value is
+// lenient: any size, unknown fields ignored and trailing data unchecked.
+// Set them for a whole router with Router.SetDecodeOptions, or for a single
+// route with Route.Decode.
+type DecodeOptions struct {
+	MaxBodyBytes          int64 // Larger bodies are answered with 413; 0 means no limit, except 10 MiB for URL-encoded forms
+	DisallowUnknownFields bool  // Reject fields the target struct doesn't declare
+	DisallowTrailingData  bool  // Reject anything but whitespace after the JSON value
+	UseNumber             bool  // Decode numbers into interface values as json.Number, not float64
+	MaxMemory             int64 // Multipart bytes kept in memory before files spill to temp files; 32 MiB when 0
+}
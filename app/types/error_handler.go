@@ -0,0 +1,42 @@
+package types
+
+import "errors"
+
+// ErrorHandler turns errors into responses, like Spring's @ExceptionHandler.
+// Handle returns nil for errors it doesn't deal with, so the next handler
+// gets a chance.
+type ErrorHandler interface {
+	Handle(ctx *MiddlewareContext, err error) *ResponseEntity
+}
+
+// ErrorHandlerFunc adapts a plain function to ErrorHandler.
+type ErrorHandlerFunc func(ctx *MiddlewareContext, err error) *ResponseEntity
+
+func (f ErrorHandlerFunc) Handle(ctx *MiddlewareContext, err error) *ResponseEntity {
+	return f(ctx, err)
+}
+
+// --- Error handler builders --- \\
+
+// OnError handles errors that match target with errors.Is, such as a
+// sentinel like sql.ErrNoRows.
+func OnError(target error, handle func(ctx *MiddlewareContext, err error) *ResponseEntity) ErrorHandler {
+	return ErrorHandlerFunc(func(ctx *MiddlewareContext, err error) *ResponseEntity {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		return handle(ctx, err)
+	})
+}
+
+// OnErrorAs handles errors that contain an E, found with errors.As. The
+// handler receives the E itself, e.g. a *pgconn.PgError.
+func OnErrorAs[E error](handle func(ctx *MiddlewareContext, err E) *ResponseEntity) ErrorHandler {
+	return ErrorHandlerFunc(func(ctx *MiddlewareContext, err error) *ResponseEntity {
+		var target E
+		if !errors.As(err, &target) {
+			return nil
+		}
+		return handle(ctx, target)
+	})
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// MergePatch is a JSON Merge Patch document (RFC 7386), as sent with the
+// "application/merge-patch+json" content type. Declare a handler parameter of
+// this type to receive the raw patch, then apply it to the stored resource:
+//
+//	func (c *UserController) Patch(id int, patch types.MergePatch) *types.ResponseEntity {
+//		user := c.users.Find(id)
+//		if err := patch.ApplyTo(&user); err != nil { ... }
+//		...
+//	}
+//
+// Members set to null are removed, objects are merged recursively and any
+// other value replaces what was there.
+type MergePatch json.RawMessage
+
+// UnmarshalJSON keeps a copy of the raw patch document.
+func (p *MergePatch) UnmarshalJSON(data []byte) error {
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// MarshalJSON returns the raw patch document.
+func (p MergePatch) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+// ApplyTo merges the patch into target, which must be a non-nil pointer.
+// An empty patch leaves target untouched.
+func (p MergePatch) ApplyTo(target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("merge patch target must be a non-nil pointer")
+	}
+	if len(p) == 0 {
+		return nil
+	}
+
+	var patch any
+	if err := json.Unmarshal(p, &patch); err != nil {
+		return err
+	}
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return err
+	}
+
+	// Start from the zero value so removed members don't survive the decode
+	updated := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(merged, updated.Interface()); err != nil {
+		return err
+	}
+	rv.Elem().Set(updated.Elem())
+	return nil
+}
+
+// mergePatch implements the MergePatch algorithm from RFC 7386, section 2.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
@@ -1,7 +1,7 @@
 package types
 
 import (
-	"github.com/isaacwallace123/GoUtils/logger"
+	"fmt"
 	"net/http"
 )
 
@@ -43,6 +43,7 @@ var PostMiddlewares []Middleware
 
 // MiddlewareBuilder is a reusable, typed middleware object with attached config and logic.
 type MiddlewareBuilder[T any] struct {
+	Name           string // Named in the errors the middleware returns
 	Config         *T
 	Handler        MiddlewareFunc
 	OnErrorHandler func(ctx *MiddlewareContext, err error)
@@ -51,8 +52,15 @@ type MiddlewareBuilder[T any] struct {
 // Func allows the builder to be treated as a Middleware interface.
 func (middleware *MiddlewareBuilder[T]) Func() MiddlewareFunc {
 	return func(ctx *MiddlewareContext) error {
+		start := ctx.Index
 		err := middleware.Handler(ctx)
 
+		// Only the middleware's own errors, returned before it passed the request on,
+		// are named after it; errors from further down the chain go up unchanged
+		if err != nil && ctx.Index == start && middleware.Name != "" {
+			err = fmt.Errorf("middleware '%s': %w", middleware.Name, err)
+		}
+
 		if err != nil && middleware.OnErrorHandler != nil {
 			middleware.OnErrorHandler(ctx, err)
 
@@ -69,25 +77,26 @@ func (middleware *MiddlewareBuilder[T]) WithInit(initFn func(*T)) *MiddlewareBui
 	return middleware
 }
 
+// OnError handles the middleware's errors itself; they no longer reach the error handlers.
 func (middleware *MiddlewareBuilder[T]) OnError(handler func(ctx *MiddlewareContext, err error)) *MiddlewareBuilder[T] {
 	middleware.OnErrorHandler = handler
 	return middleware
 }
 
 // NewMiddlewareBuilder constructs a typed middleware with a config and handler function.
+// Errors go up the chain to the router's error handlers, wrapped with name so
+// logs tell which middleware failed, unless OnError is set to handle them.
 func NewMiddlewareBuilder[T any](
 	name string,
 	defaultConfig *T,
 	handler func(ctx *MiddlewareContext, config *T) error,
 ) *MiddlewareBuilder[T] {
 	builder := &MiddlewareBuilder[T]{
+		Name:   name,
 		Config: defaultConfig,
 		Handler: func(ctx *MiddlewareContext) error {
 			return handler(ctx, defaultConfig)
 		},
-		OnErrorHandler: func(ctx *MiddlewareContext, err error) {
-			logger.Error("Middleware '%s' error: %v\n", name, err)
-		},
 	}
 	return builder
 }
@@ -0,0 +1,6 @@
+package types
+
+// PanicReporter is told about every panic recovered while serving a request,
+// e.g. to forward it to an error tracker. Stack is the goroutine's stack
+// trace at the point of the panic.
+type PanicReporter func(ctx *MiddlewareContext, recovered any, stack []byte)
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ArgumentResolver produces handler arguments of types the binder doesn't
+// know about, such as an authenticated principal or a database transaction.
+type ArgumentResolver interface {
+	Supports(t reflect.Type) bool
+	Resolve(ctx *MiddlewareContext, t reflect.Type) (reflect.Value, error)
+}
+
+// ArgumentResolvers holds globally registered resolvers. They are consulted
+// in order, before the built-in binding rules.
+var ArgumentResolvers []ArgumentResolver
+
+// --- Resolver builders --- \\
+
+// typeResolver resolves exactly one type.
+type typeResolver[T any] struct {
+	resolve func(ctx *MiddlewareContext) (T, error)
+}
+
+// ResolverFor builds a resolver for handler parameters of exactly type T.
+func ResolverFor[T any](resolve func(ctx *MiddlewareContext) (T, error)) ArgumentResolver {
+	return &typeResolver[T]{resolve: resolve}
+}
+
+func (r *typeResolver[T]) Supports(t reflect.Type) bool {
+	return t == reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (r *typeResolver[T]) Resolve(ctx *MiddlewareContext, _ reflect.Type) (reflect.Value, error) {
+	value, err := r.resolve(ctx)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	// Going through a pointer keeps nil interface values typed
+	return reflect.ValueOf(&value).Elem(), nil
+}
+
+// predicateResolver resolves any type its predicate accepts.
+type predicateResolver struct {
+	supports func(t reflect.Type) bool
+	resolve  func(ctx *MiddlewareContext, t reflect.Type) (any, error)
+}
+
+// ResolverWhen builds a resolver for every handler parameter type accepted by
+// supports, e.g. all types implementing an interface. The value returned by
+// resolve must be assignable to the requested type.
+func ResolverWhen(
+	supports func(t reflect.Type) bool,
+	resolve func(ctx *MiddlewareContext, t reflect.Type) (any, error),
+) ArgumentResolver {
+	return &predicateResolver{supports: supports, resolve: resolve}
+}
+
+func (r *predicateResolver) Supports(t reflect.Type) bool {
+	return r.supports(t)
+}
+
+func (r *predicateResolver) Resolve(ctx *MiddlewareContext, t reflect.Type) (reflect.Value, error) {
+	value, err := r.resolve(ctx, t)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if value == nil {
+		return reflect.Zero(t), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if !rv.Type().AssignableTo(t) {
+		return reflect.Value{}, &ResolverTypeError{Want: t, Got: rv.Type()}
+	}
+	return rv, nil
+}
+
+// ResolverTypeError reports a resolver that returned a value of the wrong
+// type. It is a bug in the resolver, so the client gets a 500.
+type ResolverTypeError struct {
+	Want reflect.Type // Type the handler parameter asked for
+	Got  reflect.Type // Type the resolver returned
+}
+
+func (e *ResolverTypeError) Error() string {
+	return fmt.Sprintf("argument resolver for %s returned a %s", e.Want, e.Got)
+}
@@ -4,4 +4,8 @@ type Route struct {
 	Method  string
 	Path    string
 	Handler string
+	Name    string         // Optional, used to build links with Router.URLFor
+	Decode  *DecodeOptions // Optional, replaces the router's decoding options
+	Params  []string       // Optional, the path variable each handler argument binds to ("" for none)
+	Status  int            // Optional, the status for plain return values (e.g. 201); 200, or 204 without a value, by default
 }
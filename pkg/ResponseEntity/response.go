@@ -3,6 +3,7 @@ package ResponseEntity
 import (
 	"github.com/isaacwallace123/GoUtils/jsonutil"
 	"net/http"
+	"strconv"
 )
 
 type ResponseEntity struct {
@@ -46,14 +47,19 @@ func (response *ResponseEntity) Send(writer http.ResponseWriter) {
 		writer.Header().Set(k, v)
 	}
 
+	var payload []byte
 	if response.BodyData != nil && response.StatusCode != http.StatusNoContent {
-		writer.Header().Set("Content-Type", "application/json")
+		payload = []byte(jsonutil.ToString(response.BodyData))
+		// Keep an explicit type such as application/problem+json
+		if _, ok := response.Headers["Content-Type"]; !ok {
+			writer.Header().Set("Content-Type", "application/json")
+		}
+		writer.Header().Set("Content-Length", strconv.Itoa(len(payload)))
 	}
 
 	writer.WriteHeader(response.StatusCode)
 
-	if response.BodyData != nil && response.StatusCode != http.StatusNoContent {
-		jsonStr := jsonutil.ToString(response.BodyData)
-		_, _ = writer.Write([]byte(jsonStr))
+	if payload != nil {
+		_, _ = writer.Write(payload)
 	}
 }
@@ -0,0 +1,61 @@
+package exception
+
+import (
+	"errors"
+
+	"github.com/isaacwallace123/GoWeb/app/types"
+)
+
+// HTTPError is an error that knows which HTTP response it should become.
+// Return one from a handler, directly or wrapped, to answer with its status:
+//
+//	if user == nil {
+//		return nil, exception.NewHTTPError(HttpStatus.NOT_FOUND, "user not found")
+//	}
+type HTTPError struct {
+	Status  int    // HTTP status code to answer with
+	Message string // Message shown to the client
+	Details any    // Optional, sent as the "error" field of the response
+	Err     error  // Optional underlying cause, never shown to the client
+}
+
+// NewHTTPError creates an HTTPError with the given status and client-facing message.
+func NewHTTPError(status int, message string) *HTTPError {
+	return &HTTPError{Status: status, Message: message}
+}
+
+// WrapHTTPError creates an HTTPError that keeps err as its cause, so
+// errors.Is and errors.As still see it.
+func WrapHTTPError(status int, message string, err error) *HTTPError {
+	return &HTTPError{Status: status, Message: message, Err: err}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Response builds the response the error stands for.
+func (e *HTTPError) Response() *types.ResponseEntity {
+	if e.Details != nil {
+		return GenericHTTPError(e.Status, e.Message, e.Details)
+	}
+	return GenericHTTPError(e.Status, e.Message)
+}
+
+// FromError maps an error returned by a handler to a response. An HTTPError
+// anywhere in the chain answers with its own status and message; any other
+// error becomes a 500 that hides its detail from the client.
+func FromError(err error) *types.ResponseEntity {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Response()
+	}
+	return InternalServerException("Internal server error")
+}
@@ -7,7 +7,14 @@ import (
 	"github.com/isaacwallace123/GoWeb/pkg/ResponseEntity"
 )
 
+// GenericHTTPError builds an error response. The optional extra is sent as the
+// "error" member, or, with UseProblemDetails, merged into the problem's
+// extension members.
 func GenericHTTPError(status int, message string, extras ...any) *types.ResponseEntity {
+	if ProblemDetailsEnabled() {
+		return problemFromExtras(status, message, extras).Response()
+	}
+
 	payload := map[string]any{
 		"status":    status,
 		"message":   message,
@@ -21,18 +28,171 @@ func GenericHTTPError(status int, message string, extras ...any) *types.Response
 	return ResponseEntity.Status(status).Body(payload)
 }
 
+// --- 4xx: Client Errors --- \\
+
 func BadRequestException(message string) *types.ResponseEntity {
 	return GenericHTTPError(HttpStatus.BAD_REQUEST, message)
 }
 
+func UnauthorizedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.UNAUTHORIZED, message)
+}
+
+func PaymentRequiredException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.PAYMENT_REQUIRED, message)
+}
+
+func ForbiddenException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.FORBIDDEN, message)
+}
+
 func NotFoundException(message string) *types.ResponseEntity {
 	return GenericHTTPError(HttpStatus.NOT_FOUND, message)
 }
 
+func MethodNotAllowedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.METHOD_NOT_ALLOWED, message)
+}
+
+// MethodNotAllowed is kept for existing callers; prefer MethodNotAllowedException.
+func MethodNotAllowed(message string) *types.ResponseEntity {
+	return MethodNotAllowedException(message)
+}
+
+func NotAcceptableException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.NOT_ACCEPTABLE, message)
+}
+
+func ProxyAuthRequiredException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.PROXY_AUTH_REQUIRED, message)
+}
+
+func RequestTimeoutException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.REQUEST_TIMEOUT, message)
+}
+
+func ConflictException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.CONFLICT, message)
+}
+
+func GoneException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.GONE, message)
+}
+
+func LengthRequiredException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.LENGTH_REQUIRED, message)
+}
+
+func PreconditionFailedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.PRECONDITION_FAILED, message)
+}
+
+func RequestEntityTooLargeException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.REQUEST_ENTITY_TOO_LARGE, message)
+}
+
+func RequestURITooLongException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.REQUEST_URI_TOO_LONG, message)
+}
+
+func UnsupportedMediaTypeException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.UNSUPPORTED_MEDIA_TYPE, message)
+}
+
+func RequestedRangeNotSatisfiableException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.REQUESTED_RANGE_NOT_SATISFIABLE, message)
+}
+
+func ExpectationFailedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.EXPECTATION_FAILED, message)
+}
+
+func ImATeapotException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.IM_A_TEAPOT, message)
+}
+
+func MisdirectedRequestException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.MISDIRECTED_REQUEST, message)
+}
+
+func UnprocessableEntityException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.UNPROCESSABLE_ENTITY, message)
+}
+
+func LockedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.LOCKED, message)
+}
+
+func FailedDependencyException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.FAILED_DEPENDENCY, message)
+}
+
+func TooEarlyException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.TOO_EARLY, message)
+}
+
+func UpgradeRequiredException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.UPGRADE_REQUIRED, message)
+}
+
+func PreconditionRequiredException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.PRECONDITION_REQUIRED, message)
+}
+
+func TooManyRequestsException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.TOO_MANY_REQUESTS, message)
+}
+
+func RequestHeaderFieldsTooLargeException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.REQUEST_HEADER_FIELDS_TOO_LARGE, message)
+}
+
+func UnavailableForLegalReasonsException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.UNAVAILABLE_FOR_LEGAL_REASONS, message)
+}
+
+// --- 5xx: Server Errors --- \\
+
 func InternalServerException(message string) *types.ResponseEntity {
 	return GenericHTTPError(HttpStatus.INTERNAL_SERVER_ERR, message)
 }
 
-func MethodNotAllowed(message string) *types.ResponseEntity {
-	return GenericHTTPError(HttpStatus.METHOD_NOT_ALLOWED, message)
+func NotImplementedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.NOT_IMPLEMENTED, message)
+}
+
+func BadGatewayException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.BAD_GATEWAY, message)
+}
+
+func ServiceUnavailableException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.SERVICE_UNAVAILABLE, message)
+}
+
+func GatewayTimeoutException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.GATEWAY_TIMEOUT, message)
+}
+
+func HTTPVersionNotSupportedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.HTTP_VERSION_NOT_SUPPORTED, message)
+}
+
+func VariantAlsoNegotiatesException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.VARIANT_ALSO_NEGOTIATES, message)
+}
+
+func InsufficientStorageException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.INSUFFICIENT_STORAGE, message)
+}
+
+func LoopDetectedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.LOOP_DETECTED, message)
+}
+
+func NotExtendedException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.NOT_EXTENDED, message)
+}
+
+func NetworkAuthenticationRequiredException(message string) *types.ResponseEntity {
+	return GenericHTTPError(HttpStatus.NETWORK_AUTHENTICATION_REQUIRED, message)
 }
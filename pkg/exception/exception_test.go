@@ -0,0 +1,104 @@
+package exception
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	return body
+}
+
+func TestGenericHTTPError_Legacy(t *testing.T) {
+	UseProblemDetails(false)
+
+	w := httptest.NewRecorder()
+	ConflictException("email already registered").Send(w)
+
+	body := decode(t, w)
+	if w.Code != http.StatusConflict || body["message"] != "email already registered" || body["timestamp"] == nil {
+		t.Errorf("want legacy 409 body, got %d %v", w.Code, body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("want application/json, got %q", ct)
+	}
+}
+
+func TestGenericHTTPError_ProblemDetails(t *testing.T) {
+	UseProblemDetails(true)
+	defer UseProblemDetails(false)
+
+	w := httptest.NewRecorder()
+	GenericHTTPError(http.StatusBadRequest, "invalid value for parameter 'id'", map[string]string{
+		"parameter": "id",
+		"reason":    "not a valid int",
+	}).Send(w)
+
+	if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("want %s, got %q", ProblemContentType, ct)
+	}
+	body := decode(t, w)
+	want := map[string]any{
+		"type":      "about:blank",
+		"title":     "Bad Request",
+		"status":    float64(400),
+		"detail":    "invalid value for parameter 'id'",
+		"parameter": "id",
+		"reason":    "not a valid int",
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("member %q: want %v, got %v", key, value, body[key])
+		}
+	}
+	if _, ok := body["timestamp"]; ok {
+		t.Errorf("want no legacy members, got %v", body)
+	}
+}
+
+func TestGenericHTTPError_ProblemErrorsList(t *testing.T) {
+	UseProblemDetails(true)
+	defer UseProblemDetails(false)
+
+	w := httptest.NewRecorder()
+	GenericHTTPError(http.StatusUnprocessableEntity, "request validation failed", []string{"name is required"}).Send(w)
+
+	errs, ok := decode(t, w)["errors"].([]any)
+	if !ok || len(errs) != 1 || errs[0] != "name is required" {
+		t.Errorf("want an errors member, got %v", errs)
+	}
+}
+
+func TestProblem_Custom(t *testing.T) {
+	w := httptest.NewRecorder()
+	problem := NewProblem(http.StatusForbidden, "Your balance is 30, but that costs 50.")
+	problem.Type = "https://example.com/probs/out-of-credit"
+	problem.Instance = "/account/12345/msgs/abc"
+	problem.With("balance", 30).Response().Send(w)
+
+	body := decode(t, w)
+	if body["type"] != problem.Type || body["instance"] != problem.Instance || body["balance"] != float64(30) {
+		t.Errorf("want custom members, got %v", body)
+	}
+}
+
+func TestFromError_ProblemDetails(t *testing.T) {
+	UseProblemDetails(true)
+	defer UseProblemDetails(false)
+
+	w := httptest.NewRecorder()
+	FromError(errors.New("secret")).Send(w)
+
+	body := decode(t, w)
+	if w.Code != http.StatusInternalServerError || body["detail"] != "Internal server error" {
+		t.Errorf("want a 500 problem hiding the cause, got %d %v", w.Code, body)
+	}
+}
@@ -0,0 +1,112 @@
+package exception
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/isaacwallace123/GoWeb/app/types"
+	"github.com/isaacwallace123/GoWeb/pkg/ResponseEntity"
+)
+
+// ProblemContentType is the media type of RFC 9457 Problem Details documents.
+const ProblemContentType = "application/problem+json"
+
+var problemDetails atomic.Bool
+
+// UseProblemDetails switches every error response built by this package,
+// including the router's own 400, 404, 405, 422 and 500 answers, to
+// RFC 9457 Problem Details served as application/problem+json. It is off by
+// default, which keeps the {status, message, timestamp, error} format.
+func UseProblemDetails(enabled bool) {
+	problemDetails.Store(enabled)
+}
+
+// ProblemDetailsEnabled reports whether UseProblemDetails is on.
+func ProblemDetailsEnabled() bool {
+	return problemDetails.Load()
+}
+
+// Problem is an RFC 9457 Problem Details document. Extensions are serialised
+// as top-level members next to the standard ones.
+type Problem struct {
+	Type       string         // URI identifying the problem type; "about:blank" when empty
+	Title      string         // Short summary of the problem type
+	Status     int            // HTTP status code
+	Detail     string         // Explanation specific to this occurrence
+	Instance   string         // URI identifying this occurrence; the router fills in the request path
+	Extensions map[string]any // Additional members, e.g. "errors"
+}
+
+// NewProblem creates a Problem for status with the standard status text as
+// its title.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// With adds an extension member.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// Response builds an application/problem+json response carrying the problem.
+func (p *Problem) Response() *types.ResponseEntity {
+	return ResponseEntity.Status(p.Status).
+		Header("Content-Type", ProblemContentType).
+		Body(p)
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = "about:blank"
+	}
+	members["status"] = p.Status
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// problemFromExtras turns the optional extras of GenericHTTPError into
+// extension members: maps are merged in, anything else becomes "errors".
+func problemFromExtras(status int, message string, extras []any) *Problem {
+	problem := NewProblem(status, message)
+	if len(extras) == 0 || extras[0] == nil {
+		return problem
+	}
+
+	switch extra := extras[0].(type) {
+	case map[string]string:
+		for key, value := range extra {
+			problem.With(key, value)
+		}
+	case map[string]any:
+		for key, value := range extra {
+			problem.With(key, value)
+		}
+	default:
+		problem.With("errors", extra)
+	}
+	return problem
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/isaacwallace123/GoUtils/color"
+	"github.com/isaacwallace123/GoUtils/logger"
+	"github.com/isaacwallace123/GoWeb/app/types"
+	"github.com/isaacwallace123/GoWeb/pkg/exception"
+)
+
+type RecoveryConfig struct {
+	LogStack bool                // Log the stack trace along with the panic
+	Reporter types.PanicReporter // Optional, told about every recovered panic
+}
+
+var RECOVERY_TAG = fmt.Sprintf("%sRecovery%s", color.BrightRed, color.Reset)
+
+// Recovers panics in the rest of the chain and answers with a 500. The router
+// already does this for every request; register it, globally or on a single
+// controller, to customise logging and reporting.
+var Recovery = types.NewMiddlewareBuilder("recovery", &RecoveryConfig{
+	LogStack: true,
+}, func(ctx *types.MiddlewareContext, config *RecoveryConfig) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+
+		stack := debug.Stack()
+		if config.LogStack {
+			logger.Error("%s panic serving %s %s: %v\n%s", RECOVERY_TAG, ctx.Request.Method, ctx.Request.URL.Path, recovered, stack)
+		} else {
+			logger.Error("%s panic serving %s %s: %v", RECOVERY_TAG, ctx.Request.Method, ctx.Request.URL.Path, recovered)
+		}
+
+		if config.Reporter != nil {
+			config.Reporter(ctx, recovered, stack)
+		}
+
+		// Don't append a 500 to a response the handler already started
+		if written, ok := ctx.ResponseWriter.(interface{ Written() bool }); !ok || !written.Written() {
+			ctx.ResponseEntity = exception.InternalServerException("Internal server error")
+		}
+		err = nil
+	}()
+
+	return ctx.Next()
+})
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/isaacwallace123/GoWeb/app/types"
+)
+
+func panickingHandler(ctx *types.MiddlewareContext) error {
+	var m map[string]int
+	m["boom"] = 1 // Assignment to a nil map
+	return ctx.Next()
+}
+
+func TestRecovery_RecoversPanic(t *testing.T) {
+	var reported any
+	var stack []byte
+	Recovery.Config.Reporter = func(ctx *types.MiddlewareContext, recovered any, s []byte) {
+		reported, stack = recovered, s
+	}
+	defer func() { Recovery.Config.Reporter = nil }()
+
+	ctx := &types.MiddlewareContext{
+		Request:        httptest.NewRequest("GET", "/test/panic", nil),
+		ResponseWriter: httptest.NewRecorder(),
+		Index:          -1,
+		Chain:          []types.MiddlewareFunc{Recovery.Func(), panickingHandler},
+	}
+
+	logs := captureStdout(func() {
+		if err := ctx.Next(); err != nil {
+			t.Errorf("want no error after recovery, got %v", err)
+		}
+	})
+
+	if ctx.ResponseEntity == nil || ctx.ResponseEntity.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("want a 500 response, got %+v", ctx.ResponseEntity)
+	}
+	if reported == nil || !strings.Contains(string(stack), "panickingHandler") {
+		t.Errorf("want the panic and its stack reported, got %v", reported)
+	}
+	if !strings.Contains(logs, "GET /test/panic") {
+		t.Errorf("want the request in the log, got %q", logs)
+	}
+}
+
+func TestRecovery_PassesThrough(t *testing.T) {
+	ctx := &types.MiddlewareContext{
+		Request:        httptest.NewRequest("GET", "/test/ok", nil),
+		ResponseWriter: httptest.NewRecorder(),
+		Index:          -1,
+		Chain:          []types.MiddlewareFunc{Recovery.Func(), dummyHandler},
+	}
+
+	if err := ctx.Next(); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if ctx.ResponseEntity == nil || ctx.ResponseEntity.StatusCode != http.StatusOK {
+		t.Errorf("want the handler's 200 response, got %+v", ctx.ResponseEntity)
+	}
+}
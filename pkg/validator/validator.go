@@ -0,0 +1,303 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Rule checks a single field against the parameter given in its tag
+// (e.g. "3" for `validate:"min=3"`). It returns nil when the value is valid,
+// or an error whose message describes the problem ("must be at least 3").
+type Rule func(value reflect.Value, param string) error
+
+// FieldError describes one field that failed one rule.
+type FieldError struct {
+	Field   string `json:"field"`   // Path of the field, e.g. "address.city" or "items[2].sku"
+	Rule    string `json:"rule"`    // Name of the failing rule, e.g. "min"
+	Message string `json:"message"` // Human-readable reason
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors collects every failing field of a validated value.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+var (
+	mu    sync.RWMutex
+	rules = map[string]Rule{
+		"required": required,
+		"email":    email,
+		"min":      minimum,
+		"max":      maximum,
+		"len":      length,
+		"oneof":    oneOf,
+	}
+)
+
+// Register adds a custom rule, or replaces a built-in one, under name.
+// Register rules at startup, before registering the controllers that use them.
+func Register(name string, rule Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	rules[name] = rule
+}
+
+// Validate checks v against the `validate` tags of its fields, recursing into
+// nested structs, pointers and slices. Rules are comma-separated and take an
+// optional parameter after "=": `validate:"required,min=3,max=64"`. A field
+// tagged "omitempty" skips its other rules when it holds its zero value.
+//
+// The result is nil when everything is valid, otherwise an Errors listing
+// every failing field. Field paths use JSON names when fields have them.
+func Validate(v any) error {
+	var errs Errors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// CheckTags reports every `validate` rule used by type t, or by the structs
+// nested in it, that isn't registered. The router calls it when routes are
+// registered, so a typo like `validate:"requird"` fails at startup instead of
+// on every request. Validate panics on unknown rules.
+func CheckTags(t reflect.Type) error {
+	var errs []error
+	checkType(t, "", map[reflect.Type]bool{}, &errs)
+	return errors.Join(errs...)
+}
+
+func checkType(t reflect.Type, path string, seen map[reflect.Type]bool, errs *[]error) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		checkType(t.Elem(), path, seen, errs)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = joinPath(path, fieldName(field))
+		}
+
+		for _, spec := range strings.Split(tag, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(spec), "=")
+			if name == "" || name == "omitempty" {
+				continue
+			}
+			mu.RLock()
+			_, ok := rules[name]
+			mu.RUnlock()
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("field %s uses unknown validation rule %q", fieldPath, name))
+			}
+		}
+
+		checkType(field.Type, fieldPath, seen, errs)
+	}
+}
+
+// validateValue walks into structs, pointers, slices and arrays, validating
+// every struct it finds.
+func validateValue(v reflect.Value, path string, errs *Errors) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			validateValue(v.Elem(), path, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Struct:
+		validateStruct(v, path, errs)
+	}
+}
+
+func validateStruct(v reflect.Value, path string, errs *Errors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = joinPath(path, fieldName(field))
+		}
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			checkRules(fv, fieldPath, tag, errs)
+		}
+		if field.Tag.Get("validate") != "-" {
+			validateValue(fv, fieldPath, errs)
+		}
+	}
+}
+
+// checkRules applies each rule of a tag to a single field.
+func checkRules(v reflect.Value, path, tag string, errs *Errors) {
+	specs := strings.Split(tag, ",")
+	if slices.Contains(specs, "omitempty") && v.IsZero() {
+		return
+	}
+
+	for _, spec := range specs {
+		name, param, _ := strings.Cut(strings.TrimSpace(spec), "=")
+		if name == "" || name == "omitempty" {
+			continue
+		}
+
+		mu.RLock()
+		rule, ok := rules[name]
+		mu.RUnlock()
+		if !ok {
+			// A typo in a tag is the programmer's mistake, not the client's
+			panic(fmt.Sprintf("validator: field %s uses unknown rule %q", path, name))
+		}
+
+		if err := rule(v, param); err != nil {
+			*errs = append(*errs, FieldError{Field: path, Rule: name, Message: err.Error()})
+		}
+	}
+}
+
+// fieldName returns the JSON name of a field, or its Go name without one.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// --- Built-in rules --- \\
+
+func required(v reflect.Value, _ string) error {
+	if v.IsZero() {
+		return fmt.Errorf("is required")
+	}
+	return nil
+}
+
+func email(v reflect.Value, _ string) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("must be a string")
+	}
+	addr, err := mail.ParseAddress(v.String())
+	if err != nil || addr.Address != v.String() {
+		return fmt.Errorf("must be a valid email address")
+	}
+	return nil
+}
+
+func minimum(v reflect.Value, param string) error {
+	return compare(v, param, func(n, limit float64) bool { return n >= limit }, "at least")
+}
+
+func maximum(v reflect.Value, param string) error {
+	return compare(v, param, func(n, limit float64) bool { return n <= limit }, "at most")
+}
+
+func length(v reflect.Value, param string) error {
+	return compare(v, param, func(n, limit float64) bool { return n == limit }, "exactly")
+}
+
+// compare checks a number's value, or the length of a string, slice or map,
+// against the rule parameter.
+func compare(v reflect.Value, param string, ok func(n, limit float64) bool, relation string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("has an invalid rule parameter %q", param)
+	}
+
+	var n float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return compare(v.Elem(), param, ok, relation)
+	default:
+		return fmt.Errorf("cannot be compared to %s", param)
+	}
+
+	if !ok(n, limit) {
+		if unit != "" {
+			return fmt.Errorf("must have %s %s%s", relation, param, unit)
+		}
+		return fmt.Errorf("must be %s %s", relation, param)
+	}
+	return nil
+}
+
+// oneOf accepts values listed in the space-separated parameter: `validate:"oneof=asc desc"`.
+func oneOf(v reflect.Value, param string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	options := strings.Fields(param)
+	if slices.Contains(options, fmt.Sprint(v.Interface())) {
+		return nil
+	}
+	return fmt.Errorf("must be one of [%s]", strings.Join(options, ", "))
+}
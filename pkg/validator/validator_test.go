@@ -0,0 +1,144 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"len=5"`
+}
+
+type lineItem struct {
+	SKU      string `json:"sku" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+type order struct {
+	Email    string     `json:"email" validate:"required,email"`
+	Name     string     `json:"name" validate:"min=3,max=8"`
+	Sort     string     `json:"sort" validate:"omitempty,oneof=asc desc"`
+	Address  address    `json:"address"`
+	Billing  *address   `json:"billing"`
+	Items    []lineItem `json:"items" validate:"min=1"`
+	Coupon   string     `validate:"omitempty,upper"`
+	internal string     `validate:"required"`
+}
+
+func fields(err error) []string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	paths := make([]string, len(errs))
+	for i, fe := range errs {
+		paths[i] = fe.Field + ":" + fe.Rule
+	}
+	return paths
+}
+
+func TestValidate_Valid(t *testing.T) {
+	Register("upper", func(v reflect.Value, _ string) error {
+		if strings.ToUpper(v.String()) != v.String() {
+			return fmt.Errorf("must be upper case")
+		}
+		return nil
+	})
+
+	o := order{
+		Email:   "isaac@example.com",
+		Name:    "isaac",
+		Address: address{City: "Montreal", Zip: "H2X1Y"},
+		Items:   []lineItem{{SKU: "A-1", Quantity: 2}},
+		Coupon:  "SPRING",
+	}
+	if err := Validate(&o); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+}
+
+func TestValidate_ReportsEveryField(t *testing.T) {
+	Register("upper", func(v reflect.Value, _ string) error {
+		if strings.ToUpper(v.String()) != v.String() {
+			return fmt.Errorf("must be upper case")
+		}
+		return nil
+	})
+
+	o := order{
+		Email:   "not-an-email",
+		Name:    "ab",
+		Sort:    "sideways",
+		Billing: &address{Zip: "123"},
+		Items:   []lineItem{{SKU: "A-1", Quantity: 1}, {Quantity: 11}},
+		Coupon:  "spring",
+	}
+
+	want := []string{
+		"email:email",
+		"name:min",
+		"sort:oneof",
+		"address.city:required",
+		"address.zip:len",
+		"billing.city:required",
+		"billing.zip:len",
+		"items[1].sku:required",
+		"items[1].quantity:max",
+		"Coupon:upper",
+	}
+	got := fields(Validate(o))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestValidate_EmptySlice(t *testing.T) {
+	o := order{Email: "a@b.co", Name: "isaac", Address: address{City: "x", Zip: "12345"}}
+	if got := fields(Validate(o)); !reflect.DeepEqual(got, []string{"items:min"}) {
+		t.Errorf("want [items:min], got %v", got)
+	}
+}
+
+func TestCheckTags_UnknownRule(t *testing.T) {
+	type inner struct {
+		Name string `json:"name" validate:"requird"`
+	}
+	type outer struct {
+		Items []inner `json:"items"`
+		Other *outer  `json:"other"`
+	}
+
+	err := CheckTags(reflect.TypeOf(&outer{}))
+	if err == nil || !strings.Contains(err.Error(), `items.name uses unknown validation rule "requird"`) {
+		t.Errorf("want an unknown rule error for items.name, got %v", err)
+	}
+	if err := CheckTags(reflect.TypeOf(order{})); err != nil && !strings.Contains(err.Error(), "upper") {
+		t.Errorf("want only the unregistered custom rule reported, got %v", err)
+	}
+}
+
+func TestValidate_UnknownRulePanics(t *testing.T) {
+	type bad struct {
+		Name string `validate:"shiny"`
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("want Validate to panic on an unknown rule")
+		}
+	}()
+	_ = Validate(bad{})
+}
+
+func TestValidate_NilAndNonStruct(t *testing.T) {
+	var o *order
+	if err := Validate(o); err != nil {
+		t.Errorf("want nil pointer to pass, got %v", err)
+	}
+	if err := Validate(map[string]int{"a": 1}); err != nil {
+		t.Errorf("want map to pass, got %v", err)
+	}
+}